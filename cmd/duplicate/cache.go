@@ -0,0 +1,235 @@
+package duplicate
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"shelf/common"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	CacheCmd = &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the cache of file hashes kept between 'duplicates' runs.",
+		Long:  "The first bytes and full content of hashed files are cached by device, inode, size and modification time, so unchanged files are never read twice.",
+	}
+	cacheStatsCmd = &cobra.Command{
+		Use:   "stats",
+		Short: "Shows where the hash cache is and how much it holds.",
+		Run:   runCacheStats,
+	}
+	cachePruneCmd = &cobra.Command{
+		Use:   "prune",
+		Short: "Removes the hashes of files that were deleted or changed since they were hashed.",
+		Run:   runCachePrune,
+	}
+	cacheClearCmd = &cobra.Command{
+		Use:   "clear",
+		Short: "Deletes every cached hash.",
+		Run:   runCacheClear,
+	}
+)
+
+const hashCacheFile = "hashes.gob"
+
+func init() {
+	CacheCmd.AddCommand(cacheStatsCmd, cachePruneCmd, cacheClearCmd)
+}
+
+// The digests of a file by algorithm (and chunk size, for first chunks), valid while its size and modification time
+// don't change
+type cacheEntry struct {
+	Path    string
+	Size    int64
+	ModTime int64
+	Digests map[string]string
+}
+
+// Digests of the files hashed in previous runs, loaded whole and written back when done
+type hashCache struct {
+	mutex   sync.Mutex
+	path    string
+	entries map[string]*cacheEntry
+	changed bool
+}
+
+// Path of the cache, shared by every directory scanned on the machine
+func hashCachePath() string {
+	return filepath.Join(common.GetCacheDir(), hashCacheFile)
+}
+
+// Loads the cache, a missing one is just empty
+func openHashCache() (*hashCache, error) {
+	cache := &hashCache{path: hashCachePath(), entries: make(map[string]*cacheEntry)}
+	file, err := os.Open(cache.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err := gob.NewDecoder(file).Decode(&cache.entries); err != nil {
+		return nil, fmt.Errorf("the hash cache at %s is corrupted, run 'shelf cache clear': %w", cache.path, err)
+	}
+	return cache, nil
+}
+
+// Files are found by device and inode, so a moved or renamed file keeps its hashes. Systems without inodes use the path.
+func cacheKey(path string, info os.FileInfo) string {
+	if device, inode, ok := common.FileID(info); ok {
+		return fmt.Sprintf("%d:%d", device, inode)
+	}
+	return "path:" + path
+}
+
+// Checks if the entry was made from the file as it is now
+func (entry *cacheEntry) matches(info os.FileInfo) bool {
+	return entry.Size == info.Size() && entry.ModTime == info.ModTime().UnixNano()
+}
+
+// The cached digest of a file, if it wasn't changed since it was hashed
+func (cache *hashCache) lookup(file common.FileStats, name string) (string, bool) {
+	if cache == nil {
+		return "", false
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry, ok := cache.entries[cacheKey(file.Path, file.Info)]
+	if !ok || !entry.matches(file.Info) {
+		return "", false
+	}
+
+	digest, ok := entry.Digests[name]
+	if ok && entry.Path != file.Path {
+		entry.Path = file.Path
+		cache.changed = true
+	}
+	return digest, ok
+}
+
+// Keeps the digest of a file, forgetting the ones of an older version of it
+func (cache *hashCache) store(file common.FileStats, name string, digest string) {
+	if cache == nil {
+		return
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	key := cacheKey(file.Path, file.Info)
+	entry, ok := cache.entries[key]
+	if !ok || !entry.matches(file.Info) || entry.Digests == nil {
+		entry = &cacheEntry{Size: file.Info.Size(), ModTime: file.Info.ModTime().UnixNano(), Digests: make(map[string]string)}
+		cache.entries[key] = entry
+	}
+
+	entry.Digests[name] = digest
+	entry.Path = file.Path
+	cache.changed = true
+}
+
+// Removes the entries whose file is gone or was changed, returning how many were removed
+func (cache *hashCache) prune() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	removed := 0
+	for key, entry := range cache.entries {
+		info, err := os.Stat(entry.Path)
+		if err != nil || cacheKey(entry.Path, info) != key || !entry.matches(info) {
+			delete(cache.entries, key)
+			removed++
+		}
+	}
+	cache.changed = cache.changed || removed > 0
+	return removed
+}
+
+// Writes the cache if anything changed, through a temporary file so an interrupted write never corrupts it
+func (cache *hashCache) save() error {
+	if cache == nil {
+		return nil
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if !cache.changed {
+		return nil
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(cache.path), hashCacheFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if err := gob.NewEncoder(temp).Encode(cache.entries); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temp.Name(), cache.path); err != nil {
+		return err
+	}
+	cache.changed = false
+	return nil
+}
+
+func runCacheStats(cmd *cobra.Command, args []string) {
+	cache, err := openHashCache()
+	if err != nil {
+		color.Red(err.Error())
+		return
+	}
+
+	full := 0
+	for _, entry := range cache.entries {
+		for _, algorithm := range hashEnum {
+			if _, ok := entry.Digests[algorithm]; ok {
+				full++
+				break
+			}
+		}
+	}
+
+	color.Cyan("Hash cache: %s", cache.path)
+	color.Cyan("Files: %d (%d fully hashed)", len(cache.entries), full)
+	if info, err := os.Stat(cache.path); err == nil {
+		color.Cyan("Size: %s", common.FormatBytes(info.Size()))
+		color.Cyan("Last updated: %s", info.ModTime().Format(time.DateTime))
+	}
+}
+
+func runCachePrune(cmd *cobra.Command, args []string) {
+	cache, err := openHashCache()
+	if err != nil {
+		color.Red(err.Error())
+		return
+	}
+
+	color.Cyan("Checking %d cached files...", len(cache.entries))
+	removed := cache.prune()
+	if err := cache.save(); err != nil {
+		color.Red("Couldn't save the hash cache: %v", err)
+		return
+	}
+	color.Green("Removed %d stale hashes, %d left.", removed, len(cache.entries))
+}
+
+func runCacheClear(cmd *cobra.Command, args []string) {
+	if err := os.Remove(hashCachePath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		color.Red("Couldn't clear the hash cache: %v", err)
+		return
+	}
+	color.Green("Hash cache cleared.")
+}
@@ -1,14 +1,15 @@
 package duplicate
 
 import (
-	"crypto/sha1"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
 	"shelf/common"
+	"slices"
 	"strings"
 
 	"github.com/fatih/color"
@@ -47,12 +48,23 @@ func init() {
 	DuplicateCmd.Flags().BoolP("name", "n", false, "Search for same-name files (homonymous) within the directory, including files with a number suffix. Eg. 'file (1).jpg'.")
 	DuplicateCmd.Flags().BoolP("quarantine", "q", false, "Quarantines the duplicates in a subdirectory to be manually handled.")
 	DuplicateCmd.Flags().BoolP("remove", "r", false, color.RedString("Deletes all duplicates (cannot be undone, be sure of what you're doing)."))
+	DuplicateCmd.Flags().String("link", "", "Replaces the duplicates with links to the spared file, keeping their paths. Options ['hard', 'sym', 'reflink'], reflinks only work on copy-on-write file systems.")
 	DuplicateCmd.Flags().String("spare", "oldest", "Strategy for sparing duplicates. Options ['oldest' (Default), 'newest', 'random', 'first', 'biggest', 'smallest'].")
 	DuplicateCmd.Flags().BoolP("enforce", "e", false, "Enforces the files are down-to-the-byte clones to apply its fate.")
+	DuplicateCmd.Flags().String("hash", "sha1", "Algorithm the files are compared by. Options ['sha1' (Default), 'sha256', 'sha512', 'crc32', 'blake2b'], crc32 is the fastest and the likeliest to collide.")
+	DuplicateCmd.Flags().Int64("chunkSize", 1024, "Bytes hashed at the start of same-size files to rule out most of them before hashing them whole.")
+	DuplicateCmd.Flags().IntP("jobs", "j", runtime.NumCPU(), "Number of files hashed at the same time. Use 1 on spinning disks to avoid seeking between files.")
+	DuplicateCmd.Flags().Bool("noCache", false, "Hashes every file again, without reading or updating the hash cache.")
+	DuplicateCmd.Flags().Int("throttle", 0, "Limits the reading speed of all jobs together to this many MB per second, 0 for no limit.")
 }
 
 func runDuplicates(cmd *cobra.Command, args []string) {
 	flags = cmd.Flags()
+	if err := checkFates(); err != nil {
+		color.Red(err.Error())
+		return
+	}
+
 	color.Cyan("Reading files...")
 
 	if search, _ := flags.GetBool("search"); search {
@@ -69,8 +81,19 @@ func runDuplicates(cmd *cobra.Command, args []string) {
 	sizeHash := groupByFileSize(files)
 	partialCount := len(sizeHash)
 
-	firstChunkHash := hashFirstChunks(sizeHash)
-	duplicates, fullCount := findFullDuplicates(firstChunkHash)
+	options, err := getHashOptions()
+	if err != nil {
+		color.Red(err.Error())
+		return
+	}
+	firstChunkHash := hashFirstChunks(sizeHash, options)
+	duplicates, fullCount := findFullDuplicates(firstChunkHash, options)
+	if enforce, _ := flags.GetBool("enforce"); enforce {
+		duplicates, fullCount = enforceDuplicates(duplicates, options)
+	}
+
+	spare, _ := flags.GetString("spare")
+	spareFirst(duplicates, spare)
 
 	printResults(partialCount, fullCount, duplicates)
 	handleDuplicates(duplicates)
@@ -86,44 +109,66 @@ func groupByFileSize(files []common.FileStats) map[int64][]common.FileStats {
 	return sizeHash
 }
 
-func hashFirstChunks(sizeHash map[int64][]common.FileStats) map[string][]common.FileStats {
-	color.Cyan("Hashing first 1024 bytes of files...")
-
-	chunkHash := make(map[string][]common.FileStats)
+// Hashes the first bytes of the files that share their size with another one
+func hashFirstChunks(sizeHash map[int64][]common.FileStats, options hashOptions) map[string][]common.FileStats {
+	candidates := []common.FileStats{}
 	for _, group := range sizeHash {
-		if len(group) < 2 {
-			continue
+		if len(group) > 1 {
+			candidates = append(candidates, group...)
 		}
+	}
 
-		for _, file := range group {
-			hash := getHash(file.Path, true)
-			chunkHash[hash] = append(chunkHash[hash], file)
-		}
+	color.Cyan("Hashing first %d bytes of %d files with %d jobs...", options.ChunkSize, len(candidates), options.Jobs)
+
+	chunkHash := make(map[string][]common.FileStats)
+	for _, result := range hashAll(candidates, true, options) {
+		// Files of different sizes can't be duplicates, even if they start the same
+		key := fmt.Sprintf("%d:%s", result.File.Info.Size(), result.Hash)
+		chunkHash[key] = append(chunkHash[key], result.File)
 	}
 	return chunkHash
 }
 
-func findFullDuplicates(chunkHash map[string][]common.FileStats) (map[string][]common.FileStats, int) {
-	color.Cyan("Finding full duplicates by hashing entire files...")
+// Hashes the whole content of the files whose first bytes match another one
+func findFullDuplicates(chunkHash map[string][]common.FileStats, options hashOptions) (map[string][]common.FileStats, int) {
+	candidates := []common.FileStats{}
+	for _, group := range chunkHash {
+		if len(group) > 1 {
+			candidates = append(candidates, group...)
+		}
+	}
+
+	color.Cyan("Finding full duplicates by hashing %d entire files...", len(candidates))
+
+	// Files of different sizes are never duplicates, even if a weak hash like crc32 collides
+	type fullKey struct {
+		size int64
+		hash string
+	}
+	keys := []fullKey{}
+	fullHashes := make(map[fullKey][]common.FileStats)
+	for _, result := range hashAll(candidates, false, options) {
+		key := fullKey{result.File.Info.Size(), result.Hash}
+		if _, ok := fullHashes[key]; !ok {
+			keys = append(keys, key)
+		}
+		fullHashes[key] = append(fullHashes[key], result.File)
+	}
 
 	duplicates := make(map[string][]common.FileStats)
-	fullHashes := make(map[string]common.FileStats)
 	fullCount := 0
-
-	for _, group := range chunkHash {
+	for _, key := range keys {
+		group := fullHashes[key]
 		if len(group) < 2 {
 			continue
 		}
 
-		for _, file := range group {
-			hash := getHash(file.Path, false)
-			if original, exists := fullHashes[hash]; exists {
-				duplicates[hash] = append(duplicates[hash], original, file)
-				fullCount++
-			} else {
-				fullHashes[hash] = file
-			}
+		name := key.hash
+		if _, taken := duplicates[name]; taken {
+			name = fmt.Sprintf("%s-%d", key.hash, key.size)
 		}
+		duplicates[name] = group
+		fullCount += len(group) - 1
 	}
 	return duplicates, fullCount
 }
@@ -143,11 +188,31 @@ func printResults(partialCount, fullCount int, duplicates map[string][]common.Fi
 	}
 }
 
+// Checks the fate flags before anything is read, only one fate can be given to the duplicates
+func checkFates() error {
+	remove, _ := flags.GetBool("remove")
+	quarantine, _ := flags.GetBool("quarantine")
+	link, _ := flags.GetString("link")
+	spare, _ := flags.GetString("spare")
+
+	switch {
+	case link != "" && !slices.Contains(linkEnum, link):
+		return errors.New("'--link' flag does not contain a valid option")
+	case !slices.Contains(spareEnum, spare):
+		return errors.New("'--spare' flag does not contain a valid option")
+	case remove && quarantine, remove && link != "", quarantine && link != "":
+		return errors.New("'--remove', '--quarantine' and '--link' can't be used together, pick one fate for the duplicates")
+	}
+	return nil
+}
+
 func handleDuplicates(duplicates map[string][]common.FileStats) {
 	if remove, _ := flags.GetBool("remove"); remove {
 		deleteDuplicates(duplicates)
 	} else if quarantine, _ := flags.GetBool("quarantine"); quarantine {
 		quarantineDuplicates(duplicates)
+	} else if link, _ := flags.GetString("link"); link != "" {
+		linkDuplicates(duplicates, link)
 	}
 }
 
@@ -193,27 +258,3 @@ func quarantineDuplicates(duplicates map[string][]common.FileStats) {
 		}
 	}
 }
-
-func getHash(path string, firstChunk bool) string {
-	file, err := os.Open(path)
-	if err != nil {
-		log.Fatalf("Failed to open file %s: %v", path, err)
-	}
-	defer file.Close()
-
-	hash := sha1.New()
-	if firstChunk {
-		buffer := make([]byte, 1024)
-		bytesRead, err := file.Read(buffer)
-		if err != nil && err != io.EOF {
-			log.Fatalf("Failed to read file %s: %v", path, err)
-		}
-		hash.Write(buffer[:bytesRead])
-	} else {
-		if _, err := io.Copy(hash, file); err != nil {
-			log.Fatalf("Failed to hash file %s: %v", path, err)
-		}
-	}
-
-	return fmt.Sprintf("%x", hash.Sum(nil))
-}
@@ -0,0 +1,126 @@
+package duplicate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"shelf/common"
+
+	"github.com/fatih/color"
+)
+
+const compareBlockSize = 64 * 1024
+
+// Compares each file of each group with the group's first one and splits the groups whose files aren't byte for byte
+// the same, so a hash collision or a file changed since it was hashed never gets a fate. Returns the groups left and
+// their duplicates.
+func enforceDuplicates(duplicates map[string][]common.FileStats, options hashOptions) (map[string][]common.FileStats, int) {
+	files := []common.FileStats{}
+	for _, group := range duplicates {
+		files = append(files, group...)
+	}
+	color.Cyan("Comparing %d files byte by byte...", len(files))
+
+	bar := newProgressBar(files, false, options.ChunkSize)
+	limiter := newLimiter(options.Throttle)
+	representativeMeter := &meter{limiter: limiter, progress: &progressBar{}}
+	meter := &meter{limiter: limiter, progress: bar}
+	if options.Progress {
+		bar.start()
+	}
+
+	enforced := make(map[string][]common.FileStats)
+	fullCount := 0
+	for hash, group := range duplicates {
+		clones := compareGroup(group, meter, representativeMeter)
+		if len(clones) != 1 || len(clones[0]) != len(group) {
+			color.Yellow("Files with hash %s are not all the same, %d groups of clones are left.", hash, len(clones))
+		}
+
+		for index, clone := range clones {
+			key := hash
+			if len(clones) > 1 {
+				key = fmt.Sprintf("%s-%d", hash, index+1)
+			}
+			enforced[key] = clone
+			fullCount += len(clone) - 1
+		}
+	}
+
+	if options.Progress {
+		bar.stop()
+	}
+	return enforced, fullCount
+}
+
+// Splits a group in the sets of files with the same bytes, leaving out the files that are alone or can't be read. Each
+// file is compared with the first one of the files left, only two are open at a time however big the group is. The
+// first file's reads are throttled but not counted, the progress counts each file once.
+func compareGroup(group []common.FileStats, meter *meter, representativeMeter *meter) [][]common.FileStats {
+	clones := [][]common.FileStats{}
+	for left := group; len(left) > 0; {
+		representative := left[0]
+		same, different := []common.FileStats{representative}, []common.FileStats{}
+		for _, file := range left[1:] {
+			equal, err := sameContent(representative.Path, file.Path, representativeMeter, meter)
+			if err != nil {
+				color.Red("Failed to compare %s with %s: %v", file.Path, representative.Path, err)
+				meter.progress.files.Add(1)
+				continue
+			}
+
+			if equal {
+				same = append(same, file)
+				meter.progress.files.Add(1)
+			} else {
+				different = append(different, file)
+			}
+		}
+
+		meter.progress.files.Add(1)
+		if len(same) > 1 {
+			clones = append(clones, same)
+		}
+		left = different
+	}
+	return clones
+}
+
+// Reads two files block by block, stopping at the first difference
+func sameContent(first string, second string, firstMeter *meter, secondMeter *meter) (bool, error) {
+	a, err := os.Open(first)
+	if err != nil {
+		return false, err
+	}
+	defer a.Close()
+
+	b, err := os.Open(second)
+	if err != nil {
+		return false, err
+	}
+	defer b.Close()
+
+	readerA := &meteredReader{reader: a, meter: firstMeter}
+	readerB := &meteredReader{reader: b, meter: secondMeter}
+	blockA, blockB := make([]byte, compareBlockSize), make([]byte, compareBlockSize)
+	for {
+		readA, errA := io.ReadFull(readerA, blockA)
+		if errA != nil && !errors.Is(errA, io.EOF) && !errors.Is(errA, io.ErrUnexpectedEOF) {
+			return false, errA
+		}
+		readB, errB := io.ReadFull(readerB, blockB)
+		if errB != nil && !errors.Is(errB, io.EOF) && !errors.Is(errB, io.ErrUnexpectedEOF) {
+			return false, errB
+		}
+
+		if !bytes.Equal(blockA[:readA], blockB[:readB]) {
+			return false, nil
+		}
+		if readA < compareBlockSize {
+			// Both files ended at the same byte
+			return true, nil
+		}
+	}
+}
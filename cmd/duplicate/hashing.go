@@ -0,0 +1,308 @@
+package duplicate
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"shelf/common"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatih/color"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Makers of the digests files can be compared by
+var hashAlgorithms = map[string]func() hash.Hash{
+	"crc32":   func() hash.Hash { return crc32.NewIEEE() },
+	"sha1":    sha1.New,
+	"sha256":  sha256.New,
+	"sha512":  sha512.New,
+	"blake2b": newBlake2b,
+}
+
+var hashEnum []string = []string{"sha1", "sha256", "sha512", "crc32", "blake2b"}
+
+// BLAKE2b-256 without a key, which can't fail
+func newBlake2b() hash.Hash {
+	hash, _ := blake2b.New256(nil)
+	return hash
+}
+
+// How the hashing workers run
+type hashOptions struct {
+	// Name of the hash algorithm
+	Algorithm string
+	// Bytes hashed to tell apart files of the same size before hashing them whole
+	ChunkSize int64
+	// Files hashed at the same time
+	Jobs int
+	// Bytes read per second by all workers together, 0 for no limit
+	Throttle int64
+	// Draws a progress bar on stderr
+	Progress bool
+	// Digests of previous runs, nil to hash every file
+	Cache *hashCache
+}
+
+// Digest of a file, or why it couldn't be hashed
+type hashResult struct {
+	File common.FileStats
+	Hash string
+	Err  error
+}
+
+// Reads the hashing options from the command flags
+func getHashOptions() (hashOptions, error) {
+	algorithm, _ := flags.GetString("hash")
+	chunkSize, _ := flags.GetInt64("chunkSize")
+	jobs, _ := flags.GetInt("jobs")
+	throttle, _ := flags.GetInt("throttle")
+	quiet, _ := flags.GetBool("quiet")
+	if !slices.Contains(hashEnum, algorithm) {
+		return hashOptions{}, errors.New("'--hash' flag does not contain a valid option")
+	}
+	if chunkSize < 1 {
+		return hashOptions{}, errors.New("'--chunkSize' must be at least 1 byte")
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	options := hashOptions{Algorithm: algorithm, ChunkSize: chunkSize, Jobs: jobs, Throttle: int64(throttle) * 1024 * 1024, Progress: !quiet && isTerminal(os.Stderr)}
+	if noCache, _ := flags.GetBool("noCache"); !noCache {
+		cache, err := openHashCache()
+		if err != nil {
+			color.Yellow("Hashing without the cache: %v", err)
+		}
+		options.Cache = cache
+	}
+	return options, nil
+}
+
+// Hashes the files on a pool of workers, either their first chunk or their whole content, unless the cache has them.
+// The results keep the order of the files, the ones that couldn't be read are reported and left out.
+func hashAll(files []common.FileStats, firstChunk bool, options hashOptions) []hashResult {
+	results := make([]hashResult, len(files))
+	bar := newProgressBar(files, firstChunk, options.ChunkSize)
+	meter := &meter{limiter: newLimiter(options.Throttle), progress: bar}
+	if options.Progress {
+		bar.start()
+	}
+
+	indexes := make(chan int)
+	cached := atomic.Int64{}
+	workers := sync.WaitGroup{}
+	for range min(options.Jobs, max(len(files), 1)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for index := range indexes {
+				file := files[index]
+				if hash, ok := options.Cache.lookup(file, options.digestName(firstChunk)); ok {
+					results[index] = hashResult{File: file, Hash: hash}
+					bar.bytes.Add(bar.work(file, firstChunk))
+					bar.files.Add(1)
+					cached.Add(1)
+					continue
+				}
+
+				hash, err := getHash(file.Path, firstChunk, options, meter)
+				if err == nil {
+					options.Cache.store(file, options.digestName(firstChunk), hash)
+				}
+				results[index] = hashResult{File: file, Hash: hash, Err: err}
+				bar.files.Add(1)
+			}
+		}()
+	}
+
+	for index := range files {
+		indexes <- index
+	}
+	close(indexes)
+	workers.Wait()
+
+	if options.Progress {
+		bar.stop()
+	}
+	if hits := cached.Load(); hits > 0 {
+		color.Cyan("%d of %d files were already hashed.", hits, len(files))
+	}
+	if err := options.Cache.save(); err != nil {
+		color.Yellow("Couldn't save the hash cache: %v", err)
+	}
+
+	hashed := make([]hashResult, 0, len(results))
+	for _, result := range results {
+		if result.Err != nil {
+			color.Red("Failed to hash %s: %v", result.File.Path, result.Err)
+			continue
+		}
+		hashed = append(hashed, result)
+	}
+	return hashed
+}
+
+// Hashes the first bytes of a file or the whole of it
+func getHash(path string, firstChunk bool, options hashOptions, meter *meter) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var reader io.Reader = &meteredReader{reader: file, meter: meter}
+	if firstChunk {
+		reader = io.LimitReader(reader, options.ChunkSize)
+	}
+	return digest(hashAlgorithms[options.Algorithm](), reader)
+}
+
+// Streams everything the reader has into the hash, returning the hex digest
+func digest(hash hash.Hash, reader io.Reader) (string, error) {
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Name the digest is cached under, different algorithms and chunk sizes give different digests
+func (options hashOptions) digestName(firstChunk bool) string {
+	if firstChunk {
+		return fmt.Sprintf("%s:%d", options.Algorithm, options.ChunkSize)
+	}
+	return options.Algorithm
+}
+
+// Everything the workers share while reading
+type meter struct {
+	limiter  *limiter
+	progress *progressBar
+}
+
+// Counts the bytes read and holds the reads back to the throttle
+type meteredReader struct {
+	reader io.Reader
+	meter  *meter
+}
+
+func (r *meteredReader) Read(buffer []byte) (int, error) {
+	read, err := r.reader.Read(buffer)
+	r.meter.limiter.wait(read)
+	r.meter.progress.bytes.Add(int64(read))
+	return read, err
+}
+
+// Spreads the reads of all workers so they don't go over a number of bytes per second. Spinning disks lose most of
+// their speed seeking between files, a limit (or a single job) keeps them reading sequentially and the machine usable.
+type limiter struct {
+	mutex sync.Mutex
+	rate  int64
+	next  time.Time
+}
+
+func newLimiter(rate int64) *limiter {
+	return &limiter{rate: rate}
+}
+
+// Sleeps until the given bytes fit in the rate
+func (l *limiter) wait(bytes int) {
+	if l.rate <= 0 || bytes <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(bytes) * int64(time.Second) / l.rate))
+	l.mutex.Unlock()
+
+	time.Sleep(delay)
+}
+
+// Files and bytes hashed so far, redrawn a few times a second
+type progressBar struct {
+	files      atomic.Int64
+	bytes      atomic.Int64
+	totalFiles int64
+	totalBytes int64
+	chunkSize  int64
+	done       chan bool
+	drawn      sync.WaitGroup
+}
+
+func newProgressBar(files []common.FileStats, firstChunk bool, chunkSize int64) *progressBar {
+	bar := &progressBar{totalFiles: int64(len(files)), chunkSize: chunkSize, done: make(chan bool)}
+	for _, file := range files {
+		bar.totalBytes += bar.work(file, firstChunk)
+	}
+	return bar
+}
+
+// Bytes read to hash a file
+func (bar *progressBar) work(file common.FileStats, firstChunk bool) int64 {
+	if firstChunk {
+		return min(file.Info.Size(), bar.chunkSize)
+	}
+	return file.Info.Size()
+}
+
+func (bar *progressBar) start() {
+	bar.drawn.Add(1)
+	go func() {
+		defer bar.drawn.Done()
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				bar.draw()
+			case <-bar.done:
+				bar.draw()
+				fmt.Fprintln(os.Stderr)
+				return
+			}
+		}
+	}()
+}
+
+func (bar *progressBar) stop() {
+	close(bar.done)
+	bar.drawn.Wait()
+}
+
+// Draws the bar over the previous one, like [=========>          ] 120/400 files, 1.2 GB/3.9 GB
+func (bar *progressBar) draw() {
+	const width = 30
+	bytes := bar.bytes.Load()
+	filled := width
+	if bar.totalBytes > 0 {
+		filled = int(min(bytes, bar.totalBytes) * width / bar.totalBytes)
+	}
+
+	line := strings.Repeat("=", filled)
+	if filled < width {
+		line += ">" + strings.Repeat(" ", width-filled-1)
+	}
+	fmt.Fprintf(os.Stderr, "\r[%s] %d/%d files, %s/%s ", line, bar.files.Load(), bar.totalFiles, common.FormatBytes(bytes), common.FormatBytes(bar.totalBytes))
+}
+
+// Checks if the file is a terminal, the progress bar would only clutter a redirected output
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
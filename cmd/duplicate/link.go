@@ -0,0 +1,103 @@
+package duplicate
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"shelf/common"
+
+	"github.com/fatih/color"
+)
+
+var (
+	linkEnum  []string = []string{"hard", "sym", "reflink"}
+	linkNames          = map[string]string{"hard": "hard links", "sym": "symbolic links", "reflink": "reflinks"}
+)
+
+// Replaces every duplicate but the spared one with a link to it, so the paths keep working and the space is freed
+func linkDuplicates(duplicates map[string][]common.FileStats, kind string) {
+	color.Cyan("Replacing duplicates with %s...", linkNames[kind])
+	if kind == "hard" {
+		color.Yellow("Hard links share the spared file's modification time, the duplicates' own times are lost.")
+	}
+
+	linked, reclaimed := 0, int64(0)
+	for _, group := range duplicates {
+		spared := group[0]
+		for _, file := range group[1:] {
+			if os.SameFile(spared.Info, file.Info) {
+				// Already hard links of the same file
+				continue
+			}
+			if kind == "hard" && !sameAccess(spared.Info, file.Info) {
+				color.Yellow("Skipped %s, its permissions or owner differ from %s and a hard link would change them.", file.Path, spared.Path)
+				continue
+			}
+
+			if err := replaceWithLink(spared.Path, file, kind); err != nil {
+				color.Red("Failed to link %s: %v", file.Path, err)
+				continue
+			}
+			linked++
+			reclaimed += file.Info.Size()
+		}
+	}
+	color.Green("Linked %d duplicates, %s reclaimed.", linked, common.FormatBytes(reclaimed))
+}
+
+// Checks if two files have the same permissions and owner, which a hard link would give the duplicate from the spared file
+func sameAccess(spared os.FileInfo, duplicate os.FileInfo) bool {
+	if spared.Mode() != duplicate.Mode() {
+		return false
+	}
+
+	sparedUid, sparedGid, ok := common.FileOwner(spared)
+	duplicateUid, duplicateGid, _ := common.FileOwner(duplicate)
+	return !ok || (sparedUid == duplicateUid && sparedGid == duplicateGid)
+}
+
+// Makes the link next to the duplicate and renames it over it, so the duplicate's path is never missing
+func replaceWithLink(target string, duplicate common.FileStats, kind string) error {
+	temp, err := createLink(target, duplicate, kind)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp, duplicate.Path); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return nil
+}
+
+// Creates the link under a free temporary name in the duplicate's directory, returning that name
+func createLink(target string, duplicate common.FileStats, kind string) (string, error) {
+	dir := filepath.Dir(duplicate.Path)
+	for attempt := 0; attempt < 100; attempt++ {
+		temp := filepath.Join(dir, fmt.Sprintf(".%s.%d.shelf", duplicate.Info.Name(), rand.Uint32()))
+
+		var err error
+		switch kind {
+		case "hard":
+			err = os.Link(target, temp)
+		case "sym":
+			// Relative links keep working when the whole tree is moved
+			link := target
+			if relative, relErr := filepath.Rel(dir, target); relErr == nil {
+				link = relative
+			}
+			err = os.Symlink(link, temp)
+		case "reflink":
+			err = reflink(target, temp, duplicate.Info)
+		default:
+			return "", errors.New("'--link' flag does not contain a valid option")
+		}
+
+		if !errors.Is(err, os.ErrExist) {
+			return temp, err
+		}
+	}
+	return "", fmt.Errorf("couldn't find a free temporary name in %s", dir)
+}
@@ -25,14 +25,14 @@ func removeIndexes(items []common.FileStats, idxs []int) []common.FileStats {
 
 // IsNamedDuplicate verifica se o nome do arquivo é um named duplicate, incluindo múltiplas numerações
 func isNamedDuplicate(filename string) (bool, string) {
-	baseFilename := strings.TrimSuffix(filename, filepath.Ext(filename))
+	parsed := common.ParseFilename(filename)
 
 	// Verifica o padrão com múltiplas numerações " (N)" no final do nome antes da extensão
 	re := regexp.MustCompile(`^(.*?)( \(\d+\))+$`)
-	matches := re.FindStringSubmatch(baseFilename)
+	matches := re.FindStringSubmatch(parsed.Stem)
 
 	if len(matches) > 1 {
-		return true, strings.TrimSpace(matches[1]) + filepath.Ext(filename)
+		return true, strings.TrimSpace(matches[1]) + parsed.Extension
 	}
 	return false, filename
 }
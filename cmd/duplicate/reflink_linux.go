@@ -0,0 +1,36 @@
+//go:build linux
+
+package duplicate
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Clones the target's content into a new file that shares its blocks until either is changed (FICLONE), keeping the
+// duplicate's permissions and times. Only copy-on-write file systems like Btrfs and XFS support it.
+func reflink(target string, path string, duplicate os.FileInfo) error {
+	source, err := os.Open(target)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	clone, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, duplicate.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	err = unix.IoctlFileClone(int(clone.Fd()), int(source.Fd()))
+	if closeErr := clone.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(path, duplicate.ModTime(), duplicate.ModTime())
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
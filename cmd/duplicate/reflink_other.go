@@ -0,0 +1,13 @@
+//go:build !linux
+
+package duplicate
+
+import (
+	"errors"
+	"os"
+)
+
+// Reflinks are made with the FICLONE ioctl, which only Linux has
+func reflink(target string, path string, duplicate os.FileInfo) error {
+	return errors.New("reflinks are only supported on Linux")
+}
@@ -0,0 +1,31 @@
+package duplicate
+
+import (
+	"math/rand"
+	"shelf/common"
+	"sort"
+)
+
+var spareEnum []string = []string{"oldest", "newest", "random", "first", "biggest", "smallest"}
+
+// Orders each group so the file the '--spare' strategy keeps comes first, the one removing or linking never touches.
+// Ties are broken by the natural order of the paths, so the same files always spare the same copy.
+func spareFirst(duplicates map[string][]common.FileStats, strategy string) {
+	for _, group := range duplicates {
+		common.SortBy(group, func(file common.FileStats) string { return file.Path }, common.FileNameOrder)
+
+		switch strategy {
+		case "oldest":
+			sort.SliceStable(group, func(a, b int) bool { return group[a].Info.ModTime().Before(group[b].Info.ModTime()) })
+		case "newest":
+			sort.SliceStable(group, func(a, b int) bool { return group[a].Info.ModTime().After(group[b].Info.ModTime()) })
+		case "random":
+			spared := rand.Intn(len(group))
+			group[0], group[spared] = group[spared], group[0]
+		case "biggest":
+			sort.SliceStable(group, func(a, b int) bool { return group[a].Info.Size() > group[b].Info.Size() })
+		case "smallest":
+			sort.SliceStable(group, func(a, b int) bool { return group[a].Info.Size() < group[b].Info.Size() })
+		}
+	}
+}
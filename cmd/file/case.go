@@ -0,0 +1,180 @@
+package file
+
+import (
+	"regexp"
+	"shelf/common"
+	"strings"
+	"unicode"
+)
+
+var (
+	caseEnum   []string = []string{"upper", "lower", "title", "sentence", "snake", "kebab", "camel", "pascal", "slug"}
+	caseStyles          = map[string]func(string) string{
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+		"title":    titleCase,
+		"sentence": sentenceCase,
+		"snake":    snakeCase,
+		"kebab":    kebabCase,
+		"camel":    camelCase,
+		"pascal":   pascalCase,
+		"slug":     slugCase,
+	}
+
+	// Words kept in lower case inside a title, unless they start or end it
+	minorWords = map[string]bool{
+		"a": true, "an": true, "and": true, "as": true, "at": true, "but": true, "by": true, "for": true,
+		"in": true, "nor": true, "of": true, "on": true, "or": true, "the": true, "to": true, "vs": true, "via": true,
+	}
+	titleTokens = regexp.MustCompile(`[\p{L}\p{N}'’]+|[^\p{L}\p{N}'’]+`)
+)
+
+// "the lord of the rings" to "The Lord of the Rings", keeping the separators as they are
+func titleCase(name string) string {
+	tokens := titleTokens.FindAllString(name, -1)
+
+	first, last := -1, -1
+	for index, token := range tokens {
+		if isWord(token) {
+			if first < 0 {
+				first = index
+			}
+			last = index
+		}
+	}
+
+	for index, token := range tokens {
+		if !isWord(token) {
+			continue
+		}
+
+		lower := strings.ToLower(token)
+		if minorWords[lower] && index != first && index != last {
+			tokens[index] = lower
+		} else {
+			tokens[index] = capitalize(token)
+		}
+	}
+	return strings.Join(tokens, "")
+}
+
+// "THE LORD of the rings" to "The lord of the rings"
+func sentenceCase(name string) string {
+	runes := []rune(strings.ToLower(name))
+	for index, char := range runes {
+		if unicode.IsLetter(char) {
+			runes[index] = unicode.ToUpper(char)
+			break
+		}
+	}
+	return string(runes)
+}
+
+// "The Lord of the Rings" to "the_lord_of_the_rings"
+func snakeCase(name string) string {
+	return strings.ToLower(strings.Join(splitWords(name), "_"))
+}
+
+// "The Lord of the Rings" to "the-lord-of-the-rings"
+func kebabCase(name string) string {
+	return strings.ToLower(strings.Join(splitWords(name), "-"))
+}
+
+// "The Lord of the Rings" to "theLordOfTheRings"
+func camelCase(name string) string {
+	words := splitWords(name)
+	for index, word := range words {
+		if index == 0 {
+			words[index] = strings.ToLower(word)
+		} else {
+			words[index] = capitalize(word)
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// "The Lord of the Rings" to "TheLordOfTheRings"
+func pascalCase(name string) string {
+	words := splitWords(name)
+	for index, word := range words {
+		words[index] = capitalize(word)
+	}
+	return strings.Join(words, "")
+}
+
+// "Ação & Reação!" to "acao-reacao", only ASCII letters, digits and dashes are kept
+func slugCase(name string) string {
+	words := []string{}
+	for _, word := range splitWords(common.Transliterate(name)) {
+		word = strings.Map(func(char rune) rune {
+			if char < unicode.MaxASCII && (unicode.IsLetter(char) || unicode.IsDigit(char)) {
+				return unicode.ToLower(char)
+			}
+			return -1
+		}, word)
+
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+
+	// Names with nothing left to keep, like the ones written only in CJK, stay as they are
+	if len(words) == 0 {
+		return name
+	}
+	return strings.Join(words, "-")
+}
+
+// Splits a name in words at separators and at case changes, so "myFile-name_v2" becomes "my File name v2"
+func splitWords(name string) []string {
+	words := []string{}
+	word := []rune{}
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+
+	runes := []rune(name)
+	for index, char := range runes {
+		if char == '\'' || char == '’' {
+			continue
+		}
+		if !unicode.IsLetter(char) && !unicode.IsDigit(char) {
+			flush()
+			continue
+		}
+
+		if len(word) > 0 && unicode.IsUpper(char) {
+			previous := word[len(word)-1]
+			nextIsLower := index+1 < len(runes) && unicode.IsLower(runes[index+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				flush()
+			}
+		}
+		word = append(word, char)
+	}
+	flush()
+
+	return words
+}
+
+// Upper cases the first letter of a word and lower cases the rest
+func capitalize(word string) string {
+	runes := []rune(strings.ToLower(word))
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
+
+// Checks if a title token is a word and not a separator
+func isWord(token string) bool {
+	for _, char := range token {
+		if unicode.IsLetter(char) || unicode.IsDigit(char) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package file
+
+import (
+	"fmt"
+	"path/filepath"
+	"shelf/common"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var CheckNamesCmd = &cobra.Command{
+	Use:     "check-names [path]",
+	Args:    cobra.MaximumNArgs(1),
+	Short:   "Finds the file and folder names that aren't valid on other platforms, and optionally fixes them.",
+	Example: "shelf check-names --profile windows\nshelf check-names ./shared --profile portable --fix --dry-run",
+	Long:    ``,
+	Run:     runCheckNames,
+}
+
+func runCheckNames(cmd *cobra.Command, args []string) {
+	root := common.GetCwd()
+	if len(args) > 0 {
+		path, err := filepath.Abs(args[0])
+		if err != nil {
+			fmt.Println("Couldn't find the given path:", err)
+			return
+		}
+		root = path
+	}
+
+	if !setNameProfile(cmd) {
+		return
+	}
+
+	base, _ := cmd.Flags().GetString("base")
+	files := common.ReadEntries(root, true, true)
+	var fixable []common.FileStats
+	var fixedNames []string
+	violations := 0
+	for _, file := range files {
+		relative := relativePath(root, file.Path)
+		problems := append(nameProfile.Check(file.Filename), nameProfile.CheckPath(checkedPath(file.Path, relative, base))...)
+		if len(problems) == 0 {
+			continue
+		}
+
+		violations++
+		fmt.Println(color.CyanString(relative))
+		for _, problem := range problems {
+			fmt.Println("\t" + problem)
+		}
+
+		if fixed := nameProfile.Fix(file.Filename); fixed != file.Filename {
+			fixable = append(fixable, file)
+			fixedNames = append(fixedNames, fixed)
+		}
+	}
+
+	if violations == 0 {
+		color.Green("All %d names in %s are valid for the '%s' profile.", len(files), root, nameProfile.Name)
+		return
+	}
+	color.Yellow("%d of %d names aren't valid for the '%s' profile.", violations, len(files), nameProfile.Name)
+
+	if fix, _ := cmd.Flags().GetBool("fix"); !fix {
+		return
+	}
+	if len(fixable) == 0 {
+		color.Yellow("None of the names can be fixed by renaming, shorten their paths by hand.")
+		return
+	}
+	if len(fixable) < violations {
+		color.Yellow("%d names can't be fixed by renaming alone, shorten their paths by hand.", violations-len(fixable))
+	}
+
+	originalFiles, changedFiles := relativeNames(root, fixable, fixedNames)
+	commitRenames(cmd, root, originalFiles, changedFiles)
+}
+
+// The path whose length is checked: the absolute path of the file, or its path under the '--base' folder it will be
+// copied to. Limits like the MAX_PATH of Windows count the whole path from the drive.
+func checkedPath(path string, relative string, base string) string {
+	if base == "" {
+		return path
+	}
+	return strings.TrimRight(base, `/\`) + string(filepath.Separator) + relative
+}
+
+func init() {
+	CheckNamesCmd.Flags().String("profile", "portable", "Set of file system rules to check the names against ("+strings.Join(common.NameProfileNames(), ", ")+").")
+	CheckNamesCmd.Flags().String("base", "", "Folder the files will be copied under on the other system, like 'C:\\Users\\me\\Documents'. Path lengths are measured from it instead of from the absolute path on this one.")
+	CheckNamesCmd.Flags().Bool("fix", false, "Renames the invalid files, replacing forbidden characters with '_', trimming trailing dots and spaces, normalizing and shortening the names.")
+	CheckNamesCmd.Flags().String("conflict", "suffix", "What to do when a fixed name is already taken (abort, skip, suffix, overwrite).")
+	CheckNamesCmd.Flags().Bool("dry-run", false, "Prints the old -> new names of the fixes without renaming anything.")
+}
@@ -0,0 +1,37 @@
+package file
+
+import (
+	"path/filepath"
+	"shelf/common"
+	"strings"
+	"testing"
+)
+
+func TestCheckedPathLength(t *testing.T) {
+	windows := common.NameProfiles["windows"]
+	relative := filepath.Join(strings.Repeat("d", 120), strings.Repeat("e", 120), "file.txt")
+	absolute := filepath.Join(string(filepath.Separator)+"tmp", relative)
+
+	tests := []struct {
+		name  string
+		base  string
+		valid bool
+	}{
+		{"absolute path", "", true},
+		{"short base", `C:\x`, true},
+		{"long base", `C:\Users\someone\Documents\`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			problems := windows.CheckPath(checkedPath(absolute, relative, test.base))
+			if (len(problems) == 0) != test.valid {
+				t.Errorf("CheckPath() = %v, want valid = %v", problems, test.valid)
+			}
+		})
+	}
+
+	if problems := windows.CheckPath(checkedPath(filepath.Join(string(filepath.Separator)+strings.Repeat("r", 30), relative), relative, "")); len(problems) == 0 {
+		t.Error("CheckPath() ignored the folder holding the scan root")
+	}
+}
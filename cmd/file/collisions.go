@@ -0,0 +1,171 @@
+package file
+
+import (
+	"fmt"
+	"path/filepath"
+	"shelf/common"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var collisionEnum []string = []string{"case", "unicode", "both"}
+
+// Names of a directory that would end up as the same file on a case-insensitive or normalizing file system
+type collisionGroup struct {
+	Dir   string
+	Names []string
+}
+
+var CollisionsCmd = &cobra.Command{
+	Use:     "collisions [path]",
+	Args:    cobra.MaximumNArgs(1),
+	Short:   "Finds names that collide on case-insensitive or Unicode normalizing file systems, like 'Readme.md' and 'README.md'.",
+	Example: "shelf collisions\nshelf collisions ./repo --mode case --fix --dry-run",
+	Long:    ``,
+	Run:     runCollisions,
+}
+
+func runCollisions(cmd *cobra.Command, args []string) {
+	root := common.GetCwd()
+	if len(args) > 0 {
+		path, err := filepath.Abs(args[0])
+		if err != nil {
+			fmt.Println("Couldn't find the given path:", err)
+			return
+		}
+		root = path
+	}
+
+	mode, _ := cmd.Flags().GetString("mode")
+	if !checkEnum(mode, collisionEnum) {
+		fmt.Println("'--mode' flag does not contain a valid option.")
+		return
+	}
+	if !setNameProfile(cmd) {
+		return
+	}
+
+	groups := findCollisions(relativeEntries(root, common.ReadFilesRecursive(root)), mode)
+	if len(groups) == 0 {
+		color.Green("No colliding names were found in %s.", root)
+		return
+	}
+
+	for index, group := range groups {
+		if index == 0 || groups[index-1].Dir != group.Dir {
+			color.Cyan("In %s:", filepath.Join(root, group.Dir))
+		}
+
+		// Quoted names show the code points, so composed and decomposed characters can be told apart
+		quoted := make([]string, len(group.Names))
+		for position, name := range group.Names {
+			quoted[position] = fmt.Sprintf("%+q", name)
+		}
+		fmt.Println("\t" + strings.Join(quoted, " <-> "))
+	}
+	color.Yellow("%d set(s) of colliding names were found.", len(groups))
+
+	if fix, _ := cmd.Flags().GetBool("fix"); !fix {
+		return
+	}
+
+	originalFiles, changedFiles := collisionFixes(root, groups, mode)
+	sortDeepestFirst(originalFiles, changedFiles)
+	commitRenames(cmd, root, originalFiles, changedFiles)
+}
+
+// Lists the relative paths of the files and of the directories holding them
+func relativeEntries(root string, files []common.FileStats) (entries []string) {
+	seen := make(map[string]bool)
+	for _, file := range files {
+		for path := relativePath(root, file.Path); path != "." && !seen[path]; path = filepath.Dir(path) {
+			seen[path] = true
+			entries = append(entries, path)
+		}
+	}
+	return entries
+}
+
+// Groups the names of each directory that share the same key, sorted by directory and name
+func findCollisions(entries []string, mode string) (groups []collisionGroup) {
+	siblings := make(map[string]map[string][]string)
+	for _, entry := range entries {
+		dir, name := filepath.Dir(entry), filepath.Base(entry)
+		if siblings[dir] == nil {
+			siblings[dir] = make(map[string][]string)
+		}
+
+		key := collisionKey(name, mode)
+		siblings[dir][key] = append(siblings[dir][key], name)
+	}
+
+	for dir, keys := range siblings {
+		for _, names := range keys {
+			if len(names) > 1 {
+				// Names already in NFC come first, so they are the ones kept by the fix
+				common.SortStrings(names, common.FileNameOrder)
+				sort.SliceStable(names, func(a, b int) bool {
+					return norm.NFC.IsNormalString(names[a]) && !norm.NFC.IsNormalString(names[b])
+				})
+				groups = append(groups, collisionGroup{Dir: dir, Names: names})
+			}
+		}
+	}
+
+	common.SortBy(groups, func(group collisionGroup) string { return group.Names[0] }, common.FileNameOrder)
+	common.SortBy(groups, func(group collisionGroup) string { return group.Dir }, common.FileNameOrder)
+	return groups
+}
+
+// The form two names share when the file system sees them as the same file
+func collisionKey(name string, mode string) string {
+	if mode != "case" {
+		name = norm.NFC.String(name)
+	}
+	if mode != "unicode" {
+		name = norm.NFC.String(cases.Fold().String(name))
+	}
+	return name
+}
+
+// Keeps the first name of each group and gives the others a " (n)" suffix that doesn't collide with any sibling
+func collisionFixes(root string, groups []collisionGroup, mode string) (originals []string, changed []string) {
+	taken := make(map[string]map[string]bool)
+	for _, group := range groups {
+		if taken[group.Dir] == nil {
+			taken[group.Dir] = make(map[string]bool)
+			for _, entry := range common.ReadDir(filepath.Join(root, group.Dir)) {
+				taken[group.Dir][collisionKey(entry.Name(), mode)] = true
+			}
+		}
+
+		for _, name := range group.Names[1:] {
+			filename := common.ParseFilename(norm.NFC.String(name))
+			candidate := name
+			for n := 1; ; n++ {
+				candidate = fmt.Sprintf("%s (%d)%s", filename.Stem, n, filename.Extension)
+				if !taken[group.Dir][collisionKey(candidate, mode)] {
+					break
+				}
+			}
+
+			taken[group.Dir][collisionKey(candidate, mode)] = true
+			originals = append(originals, filepath.Join(group.Dir, name))
+			changed = append(changed, filepath.Join(group.Dir, candidate))
+		}
+	}
+	return originals, changed
+}
+
+func init() {
+	CollisionsCmd.Flags().String("mode", "both", "Which names collide: 'case' for case-insensitive file systems, 'unicode' for composed vs decomposed characters, or 'both'.")
+	CollisionsCmd.Flags().Bool("fix", false, "Keeps the first name of each set and adds a \" (n)\" suffix to the others.")
+	CollisionsCmd.Flags().String("profile", "portable", "Set of file system rules the fixed names must follow ("+strings.Join(common.NameProfileNames(), ", ")+").")
+	CollisionsCmd.Flags().String("conflict", "abort", "What to do when a fixed name is already taken (abort, skip, suffix, overwrite).")
+	CollisionsCmd.Flags().Bool("dry-run", false, "Prints the old -> new names of the fixes without renaming anything.")
+}
@@ -0,0 +1,86 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"shelf/common"
+	"strings"
+)
+
+// Writes the names of the files to a temporary file, in the order they were sorted, opens it in the user's editor and
+// returns the original and the edited names, one per line, relative to the root
+func editNames(root string, files []common.FileStats) ([]string, []string, error) {
+	originals := make([]string, len(files))
+	for index, file := range files {
+		originals[index] = relativePath(root, file.Path)
+	}
+
+	if len(originals) == 0 {
+		return nil, nil, errors.New("no files were selected to edit")
+	}
+
+	temp, err := os.CreateTemp("", "shelf-rename-*.txt")
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't create the file to edit: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.WriteString(strings.Join(originals, "\n") + "\n")
+	temp.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't write the file to edit: %w", err)
+	}
+
+	if err := openEditor(temp.Name()); err != nil {
+		return nil, nil, err
+	}
+
+	content, err := os.ReadFile(temp.Name())
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't read the edited file: %w", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n"), "\n")
+	if len(lines) != len(originals) {
+		return nil, nil, fmt.Errorf("the edited file has %d lines but %d files were selected, lines can't be added or removed", len(lines), len(originals))
+	}
+
+	changed := make([]string, len(lines))
+	for index, line := range lines {
+		line = strings.TrimSpace(line)
+		if err := validateNewName(originals[index], line); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", index+1, err)
+		}
+		changed[index] = filepath.Clean(line)
+	}
+
+	sortDeepestFirst(originals, changed)
+	return originals, changed, nil
+}
+
+// Opens a file in $VISUAL or $EDITOR and waits for the editor to close
+func openEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	// The editor may come with its own arguments, like "code --wait"
+	args := strings.Fields(editor)
+	command := exec.Command(args[0], append(args[1:], path)...)
+	command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("the editor '%s' exited with an error: %w", editor, err)
+	}
+	return nil
+}
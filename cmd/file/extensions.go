@@ -0,0 +1,58 @@
+package file
+
+import (
+	"shelf/common"
+	"shelf/config"
+	"shelf/metadata"
+	"strings"
+)
+
+// Extensions that only say a file was downloaded or saved without knowing what it is
+var placeholderExtensions = map[string]bool{
+	"bin": true, "dat": true, "tmp": true, "file": true, "download": true, "unknown": true, "crdownload": true,
+}
+
+// Gives the files the extension of their content, normalized to its usual lower case spelling (".JPEG" to ".jpg").
+// Directories are left as they are.
+func fixExtensions(root string, files []common.FileStats) (originals []string, changed []string) {
+	known := metadata.KnownExtensions()
+	for alias := range config.AppConfig.ExtensionAliases {
+		known[strings.TrimPrefix(alias, ".")] = true
+	}
+
+	names := make([]string, len(files))
+	for index, file := range files {
+		names[index] = file.Filename
+		if file.Info.IsDir() {
+			continue
+		}
+
+		filename := common.ParseFilename(file.Filename)
+		extension := normalizeExtension(filename.Extension)
+
+		fileType, err := metadata.SniffType(file.Path)
+		current := strings.TrimPrefix(extension, ".")
+		switch {
+		case err != nil || fileType.Matches(current) || fileType.Extension == "":
+			// Unknown contents, or an extension that is already right, only get normalized
+		case current == "" || known[current] || placeholderExtensions[current]:
+			extension = "." + fileType.Extension
+		default:
+			// Not an extension we know, like the ".2" of "Report v1.2", so it stays part of the name
+			filename.Stem, extension = filename.Stem+filename.Extension, "."+fileType.Extension
+		}
+
+		names[index] = filename.Stem + extension
+	}
+
+	return relativeNames(root, files, names)
+}
+
+// Lower cases an extension and replaces aliases like ".jpeg" by their usual spelling
+func normalizeExtension(extension string) string {
+	extension = strings.ToLower(extension)
+	if alias, ok := config.AppConfig.ExtensionAliases[extension]; ok {
+		return alias
+	}
+	return extension
+}
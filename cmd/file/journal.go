@@ -0,0 +1,308 @@
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"shelf/common"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// What a journal entry did to the files
+type journalKind string
+
+const (
+	journalRename journalKind = "rename"
+	journalUndo   journalKind = "undo"
+	journalRedo   journalKind = "redo"
+)
+
+// A line of the rename journal. Undo and redo entries point to the rename batch they reverted or reapplied.
+type journalEntry struct {
+	ID        int             `json:"id"`
+	Kind      journalKind     `json:"kind"`
+	Batch     int             `json:"batch,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	Cwd       string          `json:"cwd"`
+	Command   string          `json:"command"`
+	Operation renameOperation `json:"operation"`
+}
+
+const journalFile = "rename_journal.jsonl"
+
+// Path of the journal, shared by every directory renamed on the machine
+func journalPath() string {
+	return filepath.Join(common.GetDataDir(), journalFile)
+}
+
+// Read all entries of the journal, a missing journal is just an empty history
+func readJournal() ([]journalEntry, error) {
+	file, err := os.Open(journalPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseJournal(file)
+}
+
+// Reads the entries of an open journal from its current position
+func parseJournal(reader io.Reader) ([]journalEntry, error) {
+	var entries []journalEntry
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("the rename history at %s is corrupted: %w", journalPath(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Appends an entry at the end of the journal, giving it the next id. The journal stays locked from reading the last
+// id to writing the entry, so two renames finishing at the same time never get the same id.
+func appendJournal(kind journalKind, batch int, operation renameOperation) error {
+	file, err := os.OpenFile(journalPath(), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := common.LockFile(file); err != nil {
+		return fmt.Errorf("couldn't lock the rename history at %s: %w", journalPath(), err)
+	}
+	entries, err := parseJournal(file)
+	if err != nil {
+		return err
+	}
+
+	entry := journalEntry{
+		ID:        1,
+		Kind:      kind,
+		Batch:     batch,
+		Timestamp: time.Now(),
+		Cwd:       common.GetCwd(),
+		Command:   commandLine(),
+		Operation: operation,
+	}
+	if len(entries) > 0 {
+		entry.ID = entries[len(entries)-1].ID + 1
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// The command line that triggered the operation, quoted so it can be copied back
+func commandLine() string {
+	args := make([]string, len(os.Args))
+	for index, arg := range os.Args {
+		if strings.ContainsAny(arg, " \t\"'") {
+			arg = strconv.Quote(arg)
+		}
+		args[index] = arg
+	}
+	return strings.Join(args, " ")
+}
+
+// Replays the journal of a directory, returning the applied batches and the undone ones (most recent last)
+func directoryHistory(entries []journalEntry, path string) (applied []journalEntry, undone []journalEntry) {
+	batches := make(map[int]journalEntry)
+	for _, entry := range entries {
+		if entry.Operation.Path != path {
+			continue
+		}
+
+		switch entry.Kind {
+		case journalRename:
+			batches[entry.ID] = entry
+			applied = append(applied, entry)
+			undone = nil
+		case journalUndo:
+			applied = removeBatch(applied, entry.Batch)
+			undone = append(undone, batches[entry.Batch])
+		case journalRedo:
+			undone = removeBatch(undone, entry.Batch)
+			applied = append(applied, batches[entry.Batch])
+		}
+	}
+	return
+}
+
+// Removes a batch from a stack of batches
+func removeBatch(stack []journalEntry, batch int) []journalEntry {
+	for index := len(stack) - 1; index >= 0; index-- {
+		if stack[index].ID == batch {
+			return append(stack[:index:index], stack[index+1:]...)
+		}
+	}
+	return stack
+}
+
+// Saves the renames of the plan as a new batch in the journal
+func makeRecord(plan *renamePlan) {
+	err := appendJournal(journalRename, 0, renameOperation{
+		Path:       plan.Path,
+		Operations: plan.operations(),
+	})
+	if err != nil {
+		color.Red("The files were renamed, but the operation couldn't be saved in the history: %v", err)
+	}
+}
+
+// Reverts the last n rename batches made in the directory, newest first
+func undoRenames(path string, n int) {
+	entries, err := readJournal()
+	if err != nil {
+		color.Red("Couldn't read the rename history: %v", err)
+		return
+	}
+
+	applied, _ := directoryHistory(entries, path)
+	if len(applied) == 0 {
+		color.Yellow("There are no rename operations to undo in %s.", path)
+		return
+	}
+	if n > len(applied) {
+		color.Yellow("Only %d rename operation(s) can be undone in this folder.", len(applied))
+		n = len(applied)
+	}
+
+	for i := 0; i < n; i++ {
+		batch := applied[len(applied)-1-i]
+		originals, changed := splitOperations(batch.Operation.Operations)
+
+		// Renames are recorded deepest first, so directories are restored before the files inside them
+		slices.Reverse(originals)
+		slices.Reverse(changed)
+		if err := verifyNames(path, changed, originals); err != nil {
+			color.Red("Couldn't undo the rename #%d: %v", batch.ID, err)
+			return
+		}
+
+		if _, err := renameFiles(changed, path, originals, conflictAbort); err != nil {
+			color.Red("Couldn't undo the rename #%d: %v", batch.ID, err)
+			return
+		}
+
+		if err := appendJournal(journalUndo, batch.ID, batch.Operation); err != nil {
+			color.Red("The rename #%d was undone, but it couldn't be saved in the history: %v", batch.ID, err)
+			return
+		}
+		color.Green("Undone rename #%d (%d files) from %s.", batch.ID, len(originals), batch.Timestamp.Format(time.DateTime))
+	}
+}
+
+// Reapplies the last undone rename batch of the directory
+func redoRenames(path string) {
+	entries, err := readJournal()
+	if err != nil {
+		color.Red("Couldn't read the rename history: %v", err)
+		return
+	}
+
+	_, undone := directoryHistory(entries, path)
+	if len(undone) == 0 {
+		color.Yellow("There are no undone rename operations to redo in %s.", path)
+		return
+	}
+
+	batch := undone[len(undone)-1]
+	originals, changed := splitOperations(batch.Operation.Operations)
+	if err := verifyNames(path, originals, changed); err != nil {
+		color.Red("Couldn't redo the rename #%d: %v", batch.ID, err)
+		return
+	}
+
+	if _, err := renameFiles(originals, path, changed, conflictAbort); err != nil {
+		color.Red("Couldn't redo the rename #%d: %v", batch.ID, err)
+		return
+	}
+
+	if err := appendJournal(journalRedo, batch.ID, batch.Operation); err != nil {
+		color.Red("The rename #%d was redone, but it couldn't be saved in the history: %v", batch.ID, err)
+		return
+	}
+	color.Green("Redone rename #%d (%d files).", batch.ID, len(originals))
+}
+
+// Prints the rename batches of a directory, oldest first
+func printHistory(path string) {
+	entries, err := readJournal()
+	if err != nil {
+		color.Red("Couldn't read the rename history: %v", err)
+		return
+	}
+
+	applied, undone := directoryHistory(entries, path)
+	if len(applied) == 0 && len(undone) == 0 {
+		color.Yellow("There are no rename operations recorded for %s.", path)
+		return
+	}
+
+	color.Cyan("Rename history of %s:", path)
+	for _, batch := range applied {
+		fmt.Printf("  #%-5d %s  %4d files  %s\n", batch.ID, batch.Timestamp.Format(time.DateTime), len(batch.Operation.Operations), batch.Command)
+	}
+	for index := len(undone) - 1; index >= 0; index-- {
+		batch := undone[index]
+		fmt.Println(color.HiBlackString("  #%-5d %s  %4d files  %s (undone)", batch.ID, batch.Timestamp.Format(time.DateTime), len(batch.Operation.Operations), batch.Command))
+	}
+}
+
+// Splits the recorded operations into the original and the changed names
+func splitOperations(operations []operationValues) (originals []string, changed []string) {
+	for _, operation := range operations {
+		originals = append(originals, operation.OriginalName)
+		changed = append(changed, operation.ChangedName)
+	}
+	return
+}
+
+// Checks that the files still have the names the journal expects, following the directories renamed earlier in the batch
+func verifyNames(path string, names []string, newNames []string) error {
+	var missing []string
+	for index, name := range names {
+		current := name
+		for earlier := index - 1; earlier >= 0; earlier-- {
+			prefix := newNames[earlier] + string(os.PathSeparator)
+			if strings.HasPrefix(current, prefix) {
+				current = names[earlier] + string(os.PathSeparator) + strings.TrimPrefix(current, prefix)
+			}
+		}
+
+		if !fileExists(filepath.Join(path, current)) {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+	if len(missing) > 5 {
+		missing = append(missing[:5], fmt.Sprintf("and %d more", len(missing)-5))
+	}
+	return fmt.Errorf("the files were changed since then, missing: %s", strings.Join(missing, ", "))
+}
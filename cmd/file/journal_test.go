@@ -0,0 +1,70 @@
+package file
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+const journalWriterEnv = "SHELF_TEST_JOURNAL_WRITER"
+
+// Keeps the journal of the test and its child processes in a temporary directory
+func useTemporaryDataDir(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, variable := range []string{"XDG_DATA_HOME", "HOME", "AppData"} {
+		t.Setenv(variable, dir)
+	}
+}
+
+// Runs as a separate process of TestAppendJournalConcurrently, file locks don't keep goroutines of one process apart
+func TestJournalWriter(t *testing.T) {
+	count, err := strconv.Atoi(os.Getenv(journalWriterEnv))
+	if err != nil {
+		t.Skip("only run by TestAppendJournalConcurrently")
+	}
+	for range count {
+		if err := appendJournal(journalRename, 0, renameOperation{Path: "/photos"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAppendJournalConcurrently(t *testing.T) {
+	useTemporaryDataDir(t)
+	const writers, entries = 6, 25
+
+	failures := make(chan string, writers)
+	waiting := sync.WaitGroup{}
+	for range writers {
+		waiting.Add(1)
+		go func() {
+			defer waiting.Done()
+			writer := exec.Command(os.Args[0], "-test.run=^TestJournalWriter$")
+			writer.Env = append(os.Environ(), journalWriterEnv+"="+strconv.Itoa(entries))
+			if output, err := writer.CombinedOutput(); err != nil {
+				failures <- string(output)
+			}
+		}()
+	}
+	waiting.Wait()
+	close(failures)
+	for output := range failures {
+		t.Fatalf("a writer failed: %s", output)
+	}
+
+	journal, err := readJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journal) != writers*entries {
+		t.Fatalf("the journal has %d entries, want %d", len(journal), writers*entries)
+	}
+	for index, entry := range journal {
+		if entry.ID != index+1 {
+			t.Fatalf("entry %d has id %d, ids must be unique and in order", index+1, entry.ID)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package file
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Header of the CSV mappings, the same names used by the rename records
+var mappingHeader = []string{"originalName", "changedName"}
+
+// Reads an old -> new mapping from a CSV or a JSON file. JSON files use the rename record format and
+// may carry the path the names are relative to.
+func readMapping(path string) (renameOperation, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return renameOperation{}, fmt.Errorf("couldn't open the mapping: %w", err)
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return decodeJSONMapping(file)
+	}
+	return decodeCSVMapping(file)
+}
+
+// Decodes a mapping in the rename record format, a bare list of values is accepted too
+func decodeJSONMapping(reader io.Reader) (renameOperation, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return renameOperation{}, err
+	}
+
+	var mapping renameOperation
+	if strings.HasPrefix(strings.TrimSpace(string(content)), "[") {
+		err = json.Unmarshal(content, &mapping.Operations)
+	} else {
+		err = json.Unmarshal(content, &mapping)
+	}
+
+	if err != nil {
+		return renameOperation{}, fmt.Errorf("the mapping is not valid JSON: %w", err)
+	}
+	return mapping, nil
+}
+
+// Decodes an "originalName,changedName" CSV mapping, the header is optional
+func decodeCSVMapping(reader io.Reader) (renameOperation, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = 2
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return renameOperation{}, fmt.Errorf("the mapping is not a valid CSV with two columns: %w", err)
+	}
+
+	if len(records) > 0 && strings.EqualFold(records[0][0], mappingHeader[0]) && strings.EqualFold(records[0][1], mappingHeader[1]) {
+		records = records[1:]
+	}
+
+	mapping := renameOperation{}
+	for _, record := range records {
+		mapping.Operations = append(mapping.Operations, operationValues{OriginalName: record[0], ChangedName: record[1]})
+	}
+	return mapping, nil
+}
+
+// Checks the mapping against the files on disk, returning the old and new names deepest first
+func mappingNames(root string, mapping renameOperation) ([]string, []string, error) {
+	if len(mapping.Operations) == 0 {
+		return nil, nil, errors.New("the mapping has no files to rename")
+	}
+
+	seen := make(map[string]bool)
+	originals, changed := make([]string, 0, len(mapping.Operations)), make([]string, 0, len(mapping.Operations))
+	for index, operation := range mapping.Operations {
+		original, newName := filepath.Clean(operation.OriginalName), filepath.Clean(operation.ChangedName)
+		if seen[original] {
+			return nil, nil, fmt.Errorf("entry %d: %s is mapped more than once", index+1, original)
+		}
+		seen[original] = true
+
+		if filepath.IsAbs(original) || escapesRoot(original) || !fileExists(filepath.Join(root, original)) {
+			return nil, nil, fmt.Errorf("entry %d: %s doesn't exist in %s", index+1, operation.OriginalName, root)
+		}
+		if err := validateNewName(original, newName); err != nil {
+			return nil, nil, fmt.Errorf("entry %d: %w", index+1, err)
+		}
+
+		originals = append(originals, original)
+		changed = append(changed, newName)
+	}
+
+	sortDeepestFirst(originals, changed)
+	return originals, changed, nil
+}
+
+// Checks if a cleaned relative path leaves the directory it is relative to, names like "..notes" stay in it
+func escapesRoot(path string) bool {
+	return path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator))
+}
+
+// Checks a new name given by the user, the file can only change its name and stay in its directory
+func validateNewName(original string, changed string) error {
+	dir, name := filepath.Split(changed)
+	switch {
+	case name == "":
+		return fmt.Errorf("%s has an empty new name", original)
+	case name == "." || name == "..":
+		return fmt.Errorf("%s can't be renamed to %s", original, name)
+	case filepath.Clean(dir) != filepath.Dir(original):
+		return fmt.Errorf("%s would be moved to another directory, only the name can be changed", original)
+	case !checkForbiddenRunes(name):
+		return fmt.Errorf("%s: new file name %s", original, forbiddenRunesMessage())
+	}
+	return nil
+}
+
+// Writes the names of the plan as a mapping, JSON if the file ends in .json and CSV otherwise ("-" for the terminal)
+func writeMapping(path string, plan *renamePlan) error {
+	mapping := renameOperation{Path: plan.Path, Operations: make([]operationValues, len(plan.Entries))}
+	for index, entry := range plan.Entries {
+		mapping.Operations[index] = operationValues{OriginalName: entry.Original, ChangedName: entry.Changed}
+	}
+
+	var writer io.Writer = os.Stdout
+	if path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(mapping)
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Write(mappingHeader)
+	for _, operation := range mapping.Operations {
+		csvWriter.Write([]string{operation.OriginalName, operation.ChangedName})
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
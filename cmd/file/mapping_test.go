@@ -0,0 +1,43 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMappingNames(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, root, "..notes", "a.txt", filepath.Join("docs", "..draft"))
+	writeFiles(t, filepath.Dir(root), filepath.Base(root)+"-outside")
+
+	tests := []struct {
+		name     string
+		original string
+		changed  string
+		valid    bool
+	}{
+		{"dotted name", "..notes", "notes", true},
+		{"dotted name in a folder", "docs/..draft", "docs/draft", true},
+		{"new dotted name", "a.txt", "..a.txt", true},
+		{"parent", "..", "up", false},
+		{"outside the root", "../" + filepath.Base(root) + "-outside", "../moved", false},
+		{"cleaned outside the root", "docs/../../" + filepath.Base(root) + "-outside", "moved", false},
+		{"absolute", filepath.Join(root, "a.txt"), "b.txt", false},
+		{"new name is the parent", "a.txt", "..", false},
+		{"moved to another folder", "a.txt", "docs/a.txt", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mapping := renameOperation{Path: root, Operations: []operationValues{{OriginalName: test.original, ChangedName: test.changed}}}
+			_, _, err := mappingNames(root, mapping)
+			if (err == nil) != test.valid {
+				t.Errorf("mappingNames() error = %v, want valid = %v", err, test.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"shelf/common"
+)
+
+// Policy applied when the target name of a rename is already taken
+type conflictPolicy string
+
+const (
+	conflictAbort     conflictPolicy = "abort"
+	conflictSkip      conflictPolicy = "skip"
+	conflictSuffix    conflictPolicy = "suffix"
+	conflictOverwrite conflictPolicy = "overwrite"
+)
+
+// Why a rename could not go through as requested
+type conflictKind int
+
+const (
+	conflictNone conflictKind = iota
+	// Another rename in the same batch wants the same name
+	conflictDuplicate
+	// A file that is not being renamed already has the name
+	conflictExists
+)
+
+var (
+	conflictEnum       []string = []string{string(conflictAbort), string(conflictSkip), string(conflictSuffix), string(conflictOverwrite)}
+	errRenameConflicts          = errors.New("some of the new names conflict with each other or with existing files")
+)
+
+// A single requested rename and how the planner resolved it
+type plannedRename struct {
+	Original  string
+	Requested string
+	Changed   string
+	Conflict  conflictKind
+	Overwrite bool
+}
+
+// A physical rename on disk, temporary names included
+type renameStep struct {
+	From string
+	To   string
+}
+
+// The full set of renames of a batch, resolved and ordered so it can be applied safely
+type renamePlan struct {
+	Path    string
+	Policy  conflictPolicy
+	Entries []plannedRename
+	Steps   []renameStep
+}
+
+// Builds the rename graph of a batch, resolves its conflicts with the policy and orders the steps
+func planRenames(originalFiles []string, path string, changedFiles []string, policy conflictPolicy) (*renamePlan, error) {
+	if len(originalFiles) != len(changedFiles) {
+		return nil, errors.New("the number of files in the list are not equal, cannot map old filenames to new filenames")
+	}
+
+	plan := &renamePlan{Path: path, Policy: policy, Entries: make([]plannedRename, len(originalFiles))}
+	for index := range originalFiles {
+		plan.Entries[index] = plannedRename{Original: originalFiles[index], Requested: changedFiles[index]}
+	}
+
+	// Skipping a rename keeps its old name taken, which may create new conflicts, so resolve until it settles
+	skipped := make(map[int]conflictKind)
+	for plan.resolve(skipped) {
+	}
+
+	for _, entry := range plan.Entries {
+		if entry.unresolved() {
+			return plan, errRenameConflicts
+		}
+	}
+
+	plan.order()
+	return plan, nil
+}
+
+// The files a batch moves by their original name, with their info to recognize them under another spelling of it
+type movingFiles map[string]os.FileInfo
+
+// Finds the moving file a name points to on disk, which is only another file than the one of that name on file
+// systems that ignore case or normalization
+func (moving movingFiles) holding(path string, name string) (string, bool) {
+	if _, ok := moving[name]; ok {
+		return name, true
+	}
+	target, err := os.Lstat(filepath.Join(path, name))
+	if err != nil {
+		return "", false
+	}
+
+	for original, info := range moving {
+		if info != nil && os.SameFile(info, target) {
+			return original, true
+		}
+	}
+	return "", false
+}
+
+// Adds a moving file, a file that can't be read is only known by its name
+func (moving movingFiles) add(path string, original string) {
+	info, _ := os.Lstat(filepath.Join(path, original))
+	moving[original] = info
+}
+
+// Runs one pass of conflict resolution, returns true if new renames had to be skipped
+func (plan *renamePlan) resolve(skipped map[int]conflictKind) bool {
+	moving := make(movingFiles)
+	for index, entry := range plan.Entries {
+		if _, skip := skipped[index]; !skip && entry.Original != entry.Requested {
+			moving.add(plan.Path, entry.Original)
+		}
+	}
+
+	claimed := make(map[string]bool)
+	newSkips := false
+	for index := range plan.Entries {
+		entry := &plan.Entries[index]
+		entry.Changed, entry.Conflict, entry.Overwrite = entry.Requested, conflictNone, false
+
+		if kind, skip := skipped[index]; skip {
+			entry.Changed, entry.Conflict = entry.Original, kind
+			continue
+		}
+		if entry.Original == entry.Requested {
+			continue
+		}
+
+		switch {
+		case claimed[entry.Requested]:
+			entry.Conflict = conflictDuplicate
+		case plan.occupied(entry.Original, entry.Requested, moving):
+			entry.Conflict = conflictExists
+		}
+
+		if entry.Conflict == conflictNone {
+			claimed[entry.Requested] = true
+			continue
+		}
+
+		switch plan.Policy {
+		case conflictSkip:
+			skipped[index] = entry.Conflict
+			entry.Changed = entry.Original
+			newSkips = true
+		case conflictSuffix:
+			entry.Changed = plan.suffixName(entry.Requested, claimed, moving)
+			claimed[entry.Changed] = true
+		case conflictOverwrite:
+			// Only untouched files can be overwritten, two renames to the same name would lose one of them
+			if entry.Conflict == conflictExists {
+				entry.Overwrite = true
+				claimed[entry.Requested] = true
+			}
+		}
+	}
+	return newSkips
+}
+
+// Checks if a name is held by a file other than the one being renamed and the ones moving away
+func (plan *renamePlan) occupied(original string, name string, moving movingFiles) bool {
+	if _, ok := moving.holding(plan.Path, name); ok {
+		return false
+	}
+	target, err := os.Lstat(filepath.Join(plan.Path, name))
+	if err != nil {
+		return false
+	}
+
+	// Case-only renames on case-insensitive file systems point to the file itself
+	source, err := os.Lstat(filepath.Join(plan.Path, original))
+	return err != nil || !os.SameFile(source, target)
+}
+
+// Finds the first free "name (n).ext" variation of a name
+func (plan *renamePlan) suffixName(name string, claimed map[string]bool, moving movingFiles) string {
+	dir, base := filepath.Split(name)
+	filename := common.ParseFilename(base)
+
+	for n := 1; ; n++ {
+		candidate := dir + fmt.Sprintf("%s (%d)%s", filename.Stem, n, filename.Extension)
+		if claimed[candidate] {
+			continue
+		}
+		if _, ok := moving[candidate]; ok || !fileExists(filepath.Join(plan.Path, candidate)) {
+			return candidate
+		}
+	}
+}
+
+// Orders the renames so no file is moved onto a name that is still in use, breaking cycles with temporary names
+func (plan *renamePlan) order() {
+	type node struct {
+		from  string
+		to    string
+		state int
+	}
+
+	nodes := make([]*node, 0, len(plan.Entries))
+	bySource := make(map[string]*node)
+	moving := make(movingFiles)
+	for _, entry := range plan.Entries {
+		if entry.Original == entry.Changed {
+			continue
+		}
+		n := &node{from: entry.Original, to: entry.Changed}
+		nodes = append(nodes, n)
+		bySource[n.from] = n
+		moving.add(plan.Path, n.from)
+	}
+
+	// The file each rename waits for, found by identity so "a" -> "B", "b" -> "A" is a cycle where case is ignored
+	blockers := make(map[*node]string)
+	for _, n := range nodes {
+		if holder, ok := moving.holding(plan.Path, n.to); ok {
+			blockers[n] = holder
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	plan.Steps = make([]renameStep, 0, len(nodes))
+	temporary := 0
+	var visit func(n *node)
+	visit = func(n *node) {
+		n.state = visiting
+		if next, ok := bySource[blockers[n]]; ok && next != n && next.state != done {
+			if next.state == visiting {
+				// Cycle: park the file holding our target under a temporary name, it moves to its own target last
+				parked := plan.temporaryName(next.from, &temporary)
+				plan.Steps = append(plan.Steps, renameStep{From: next.from, To: parked})
+				delete(bySource, next.from)
+				next.from = parked
+				bySource[parked] = next
+			} else {
+				visit(next)
+			}
+		}
+		plan.Steps = append(plan.Steps, renameStep{From: n.from, To: n.to})
+		n.state = done
+	}
+
+	for _, n := range nodes {
+		if n.state == unvisited {
+			visit(n)
+		}
+	}
+}
+
+// Creates a name that no file in the directory or in the batch is using
+func (plan *renamePlan) temporaryName(name string, counter *int) string {
+	dir := filepath.Dir(name)
+	for {
+		*counter++
+		candidate := filepath.Join(dir, fmt.Sprintf(".shelf-%d-%d.tmp", os.Getpid(), *counter))
+		if !fileExists(filepath.Join(plan.Path, candidate)) {
+			return candidate
+		}
+	}
+}
+
+// Returns the renames that will actually happen, in the record format
+func (plan *renamePlan) operations() []operationValues {
+	operations := make([]operationValues, 0, len(plan.Entries))
+	for _, entry := range plan.Entries {
+		if entry.Original != entry.Changed {
+			operations = append(operations, operationValues{OriginalName: entry.Original, ChangedName: entry.Changed})
+		}
+	}
+	return operations
+}
+
+// Applies the steps of the plan, undoing the ones already done if any of them fails
+func (plan *renamePlan) apply() error {
+	overwrites := make(map[string]bool)
+	for _, entry := range plan.Entries {
+		if entry.Overwrite {
+			overwrites[entry.Changed] = true
+		}
+	}
+
+	for index, step := range plan.Steps {
+		from, to := filepath.Join(plan.Path, step.From), filepath.Join(plan.Path, step.To)
+
+		var err error
+		if !overwrites[step.To] && plan.occupied(step.From, step.To, nil) {
+			err = fmt.Errorf("%s already exists", step.To)
+		} else {
+			err = os.Rename(from, to)
+		}
+
+		if err != nil {
+			plan.rollback(plan.Steps[:index])
+			return fmt.Errorf("couldn't rename %s to %s, no files were renamed: %w", step.From, step.To, err)
+		}
+	}
+	return nil
+}
+
+// Reverts the given steps, last one first
+func (plan *renamePlan) rollback(steps []renameStep) {
+	for index := len(steps) - 1; index >= 0; index-- {
+		step := steps[index]
+		if err := os.Rename(filepath.Join(plan.Path, step.To), filepath.Join(plan.Path, step.From)); err != nil {
+			fmt.Printf("Couldn't restore %s to %s: %v\n", step.To, step.From, err)
+		}
+	}
+}
+
+// Checks if the rename still conflicts after applying the policy
+func (entry plannedRename) unresolved() bool {
+	return entry.Conflict != conflictNone && entry.Changed == entry.Requested && !entry.Overwrite
+}
@@ -0,0 +1,175 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// Creates files whose content is their name, so moves can be followed
+func writeFiles(t *testing.T, root string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Maps each file of the directory to its content
+func readFiles(t *testing.T, root string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(root, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(content)
+	}
+	return files
+}
+
+func TestPlanRenames(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     []string
+		originals []string
+		changed   []string
+		policy    conflictPolicy
+		err       error
+		want      map[string]string
+	}{
+		{
+			name: "swap", files: []string{"a", "b"},
+			originals: []string{"a", "b"}, changed: []string{"b", "a"}, policy: conflictAbort,
+			want: map[string]string{"a": "b", "b": "a"},
+		},
+		{
+			name: "cycle of three", files: []string{"a", "b", "c"},
+			originals: []string{"a", "b", "c"}, changed: []string{"b", "c", "a"}, policy: conflictAbort,
+			want: map[string]string{"a": "c", "b": "a", "c": "b"},
+		},
+		{
+			name: "chain", files: []string{"a", "b"},
+			originals: []string{"a", "b"}, changed: []string{"b", "c"}, policy: conflictAbort,
+			want: map[string]string{"b": "a", "c": "b"},
+		},
+		{
+			name: "target on disk aborts", files: []string{"a", "b"},
+			originals: []string{"a"}, changed: []string{"b"}, policy: conflictAbort, err: errRenameConflicts,
+			want: map[string]string{"a": "a", "b": "b"},
+		},
+		{
+			name: "target on disk is suffixed", files: []string{"a.txt", "b.txt"},
+			originals: []string{"a.txt"}, changed: []string{"b.txt"}, policy: conflictSuffix,
+			want: map[string]string{"b (1).txt": "a.txt", "b.txt": "b.txt"},
+		},
+		{
+			name: "target on disk is overwritten", files: []string{"a", "b"},
+			originals: []string{"a"}, changed: []string{"b"}, policy: conflictOverwrite,
+			want: map[string]string{"b": "a"},
+		},
+		{
+			name: "same target twice", files: []string{"a", "b"},
+			originals: []string{"a", "b"}, changed: []string{"c", "c"}, policy: conflictSkip,
+			want: map[string]string{"c": "a", "b": "b"},
+		},
+		{
+			// "a" can't move onto "x", so it keeps its name and "b" can't take it either
+			name: "skips propagate", files: []string{"a", "b", "x"},
+			originals: []string{"a", "b"}, changed: []string{"x", "a"}, policy: conflictSkip,
+			want: map[string]string{"a": "a", "b": "b", "x": "x"},
+		},
+		{
+			name: "skips propagate along chains", files: []string{"a", "b", "c", "x"},
+			originals: []string{"a", "b", "c"}, changed: []string{"x", "a", "b"}, policy: conflictSkip,
+			want: map[string]string{"a": "a", "b": "b", "c": "c", "x": "x"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, test.files...)
+
+			plan, err := planRenames(test.originals, root, test.changed, test.policy)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("planRenames() error = %v, want %v", err, test.err)
+			}
+			if err == nil {
+				if err := plan.apply(); err != nil {
+					t.Fatalf("apply() error = %v", err)
+				}
+			}
+
+			files := readFiles(t, root)
+			if len(files) != len(test.want) {
+				t.Errorf("files = %v, want %v", files, test.want)
+			}
+			for name, content := range test.want {
+				if files[name] != content {
+					t.Errorf("%s holds %q, want %q (files = %v)", name, files[name], content, files)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyRollsBack(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "a", "b", "c")
+
+	plan, err := planRenames([]string{"a", "b", "c"}, root, []string{"b", "a", "d"}, conflictAbort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another program takes a target between the plan and its last step
+	writeFiles(t, root, "d")
+	if err := plan.apply(); err == nil {
+		t.Fatal("apply() moved a file onto one that appeared after the plan")
+	}
+
+	want := map[string]string{"a": "a", "b": "b", "c": "c", "d": "d"}
+	files := readFiles(t, root)
+	if len(files) != len(want) {
+		t.Errorf("files after the rollback = %v, want %v", files, want)
+	}
+	for name, content := range want {
+		if files[name] != content {
+			t.Errorf("%s holds %q after the rollback, want %q", name, files[name], content)
+		}
+	}
+}
+
+// Hard links stand for the other spelling of a name on a case-insensitive file system, where "B" opens "b"
+func TestPlanRenamesMatchesFilesByIdentity(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, "a", "b")
+	for _, link := range [][2]string{{"a", "A"}, {"b", "B"}} {
+		if err := os.Link(filepath.Join(root, link[0]), filepath.Join(root, link[1])); err != nil {
+			t.Skipf("hard links aren't supported: %v", err)
+		}
+	}
+
+	plan, err := planRenames([]string{"a", "b"}, root, []string{"B", "A"}, conflictAbort)
+	if err != nil {
+		t.Fatalf("planRenames() error = %v, a case-only swap is not a conflict", err)
+	}
+
+	// The swap is a cycle, one of the files is parked under a temporary name first
+	froms := []string{}
+	for _, step := range plan.Steps {
+		froms = append(froms, step.From)
+	}
+	if len(plan.Steps) != 3 || !slices.Contains(froms, "a") || !slices.Contains(froms, "b") {
+		t.Errorf("steps = %v, want a temporary name breaking the cycle", plan.Steps)
+	}
+}
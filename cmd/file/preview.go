@@ -0,0 +1,85 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+)
+
+// Prints an aligned old -> new table of the planned renames, without touching the disk
+func previewRenames(plan *renamePlan) {
+	width := 0
+	for _, entry := range plan.Entries {
+		width = max(width, utf8.RuneCountInString(entry.Original))
+	}
+
+	renamed, unchanged, conflicts := 0, 0, 0
+	for _, entry := range plan.Entries {
+		padding := strings.Repeat(" ", width-utf8.RuneCountInString(entry.Original))
+		if entry.Original == entry.Requested {
+			unchanged++
+			fmt.Printf("  %s%s    %s\n", color.HiBlackString(entry.Original), padding, color.HiBlackString("(unchanged)"))
+			continue
+		}
+
+		oldName, newName := highlightDiff(entry.Original, entry.Changed)
+		if entry.Conflict == conflictNone {
+			renamed++
+			fmt.Printf("  %s%s -> %s\n", oldName, padding, newName)
+			continue
+		}
+
+		reason := "collides with another rename"
+		if entry.Conflict == conflictExists {
+			reason = "a file with this name already exists"
+		}
+
+		switch {
+		case entry.unresolved():
+			conflicts++
+			_, requested := highlightDiff(entry.Original, entry.Requested)
+			fmt.Printf("%s %s%s -> %s %s\n", color.RedString("!"), oldName, padding, requested, color.RedString("(%s)", reason))
+		case entry.Original == entry.Changed:
+			unchanged++
+			fmt.Printf("%s %s%s    %s\n", color.YellowString("!"), color.HiBlackString(entry.Original), padding, color.YellowString("(skipped, %s)", reason))
+		case entry.Overwrite:
+			renamed++
+			fmt.Printf("%s %s%s -> %s %s\n", color.YellowString("!"), oldName, padding, newName, color.YellowString("(overwrites the existing file)"))
+		default:
+			renamed++
+			fmt.Printf("%s %s%s -> %s %s\n", color.YellowString("!"), oldName, padding, newName, color.YellowString("(%s, suffixed)", reason))
+		}
+	}
+
+	fmt.Println()
+	color.Cyan("Dry run: %d to rename, %d unchanged, %d conflicting. Nothing was changed on disk.", renamed, unchanged, conflicts)
+}
+
+// Colors the part of the name that differs between the old and the new names
+func highlightDiff(original string, changed string) (string, string) {
+	oldRunes, newRunes := []rune(original), []rune(changed)
+
+	prefix := 0
+	for prefix < len(oldRunes) && prefix < len(newRunes) && oldRunes[prefix] == newRunes[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(oldRunes)-prefix && suffix < len(newRunes)-prefix &&
+		oldRunes[len(oldRunes)-1-suffix] == newRunes[len(newRunes)-1-suffix] {
+		suffix++
+	}
+
+	oldName := string(oldRunes[:prefix]) + color.RedString(string(oldRunes[prefix:len(oldRunes)-suffix])) + string(oldRunes[len(oldRunes)-suffix:])
+	newName := string(newRunes[:prefix]) + color.GreenString(string(newRunes[prefix:len(newRunes)-suffix])) + string(newRunes[len(newRunes)-suffix:])
+	return oldName, newName
+}
+
+// Checks if a path exists on disk
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
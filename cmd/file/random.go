@@ -0,0 +1,150 @@
+package file
+
+import (
+	cryptorand "crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"shelf/common"
+	"time"
+)
+
+var (
+	randomFormatEnum []string = []string{"chars", "uuid", "ulid"}
+	defaultAlphabet           = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// Crockford's base 32, the alphabet of ULIDs
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+// How the '--random' names are made
+type randomOptions struct {
+	Format   string
+	Length   int
+	Alphabet string
+	Seed     int64
+	Seeded   bool
+}
+
+// Gives each file a random name that no other selected file or sibling has. With a seed the same files get the same names.
+func randomizeFiles(files []common.FileStats, extensions []string, options randomOptions) ([]string, error) {
+	if !checkEnum(options.Format, randomFormatEnum) {
+		return nil, errors.New("'--format' flag does not contain a valid option")
+	}
+
+	alphabet := []rune(options.Alphabet)
+	distinct := make(map[rune]bool)
+	for _, char := range alphabet {
+		distinct[char] = true
+	}
+	if len(distinct) < 2 {
+		return nil, errors.New("'--alphabet' must have at least two different characters")
+	}
+	if !checkForbiddenRunes(options.Alphabet) {
+		return nil, errors.New("'--alphabet' " + forbiddenRunesMessage())
+	}
+	if options.Length <= 0 {
+		options.Length = 35
+	}
+
+	seed := time.Now().UnixNano()
+	if options.Seeded {
+		seed = options.Seed
+	}
+	random := rand.New(rand.NewSource(seed))
+
+	// UUIDs are expected to be unguessable, they only come from the seeded generator when asked to be reproducible
+	var uuidSource io.Reader = cryptorand.Reader
+	if options.Seeded {
+		uuidSource = random
+	}
+
+	used := make(map[string]bool)
+	names := make([]string, len(files))
+	for index, file := range files {
+		for attempt := 0; ; attempt++ {
+			if attempt == 100 {
+				return nil, fmt.Errorf("couldn't find %d unique names, use a longer '--length' or a bigger '--alphabet'", len(files))
+			}
+
+			var name string
+			var err error
+			switch options.Format {
+			case "uuid":
+				name, err = uuidName(uuidSource)
+			case "ulid":
+				name = ulidName(random, file.Info.ModTime())
+			default:
+				name = randomName(random, alphabet, options.Length)
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			key := filepath.Join(filepath.Dir(file.Path), name+extensions[index])
+			if !used[key] && !fileExists(key) {
+				used[key] = true
+				names[index] = name
+				break
+			}
+		}
+	}
+	return names, nil
+}
+
+// Names each file after the hex SHA-256 of its contents, cut to the given length. Identical files get the same name.
+func hashFiles(files []common.FileStats, length int) ([]string, error) {
+	names := make([]string, len(files))
+	for index, file := range files {
+		if file.Info.IsDir() {
+			return nil, fmt.Errorf("%s is a directory, '--by-hash' can only name files", file.Filename)
+		}
+
+		digest, err := fileDigest(file.Path)
+		if err != nil {
+			return nil, err
+		}
+		if length > 0 {
+			digest = digest[:min(length, len(digest))]
+		}
+		names[index] = digest
+	}
+	return names, nil
+}
+
+// Create a randomized filename with the given length and alphabet
+func randomName(random *rand.Rand, alphabet []rune, length int) string {
+	name := make([]rune, length)
+	for i := range name {
+		name[i] = alphabet[random.Intn(len(alphabet))]
+	}
+	return string(name)
+}
+
+// A version 4 UUID, like 0f8fad5b-d9cb-469f-a165-70867728950e
+func uuidName(source io.Reader) (string, error) {
+	uuid := make([]byte, 16)
+	if _, err := io.ReadFull(source, uuid); err != nil {
+		return "", err
+	}
+	uuid[6] = uuid[6]&0x0F | 0x40
+	uuid[8] = uuid[8]&0x3F | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+}
+
+// A ULID, 48 bits of milliseconds and 80 random bits in 26 base 32 characters. The time is the file's modification
+// time, so the names sort like the files were changed.
+func ulidName(random *rand.Rand, modified time.Time) string {
+	name := make([]byte, 26)
+	milliseconds := uint64(modified.UnixMilli())
+	for i := 9; i >= 0; i-- {
+		name[i] = crockfordAlphabet[milliseconds&0x1F]
+		milliseconds >>= 5
+	}
+	for i := 10; i < 26; i++ {
+		name[i] = crockfordAlphabet[random.Intn(32)]
+	}
+	return string(name)
+}
@@ -0,0 +1,52 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"shelf/common"
+	"slices"
+	"testing"
+)
+
+func TestRandomizeUUIDs(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "photo.jpg")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []common.FileStats{{Info: info, Path: path, Filename: "photo.jpg"}}
+	extensions := []string{".jpg"}
+
+	uuids := func(options randomOptions) []string {
+		names := []string{}
+		for range 2 {
+			generated, err := randomizeFiles(files, extensions, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, generated...)
+		}
+		return names
+	}
+
+	version4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seeded := uuids(randomOptions{Format: "uuid", Alphabet: defaultAlphabet, Seed: 42, Seeded: true})
+	unseeded := uuids(randomOptions{Format: "uuid", Alphabet: defaultAlphabet, Seed: 42})
+	for _, name := range slices.Concat(seeded, unseeded) {
+		if !version4.MatchString(name) {
+			t.Errorf("%q is not a version 4 UUID", name)
+		}
+	}
+
+	if seeded[0] != seeded[1] {
+		t.Errorf("seeded UUIDs differ between runs: %q and %q", seeded[0], seeded[1])
+	}
+	if unseeded[0] == unseeded[1] || slices.Contains(seeded, unseeded[0]) {
+		t.Errorf("unseeded UUIDs repeat: %q", unseeded)
+	}
+}
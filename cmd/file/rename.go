@@ -5,13 +5,13 @@ Copyright © 2022 Breno Nogueira breno.s.nogueira@hotmail.com
 package file
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
-	"math/rand"
 	"os"
 	"path/filepath"
+	"regexp"
 	"shelf/common"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/fatih/color"
@@ -29,26 +29,77 @@ type renameOperation struct {
 
 var (
 	iterateEnum []string = []string{"number", "letter", "mixed"}
-	blacklist   []rune   = []rune{'/', '\\', ':', '?', '*', '<', '>', '|', '"'}
+	nameProfile          = common.NameProfiles["basic"]
 )
 
 var RenameCmd = &cobra.Command{
-	Use:     "rename",
+	Use:     "rename [path]",
+	Args:    cobra.MaximumNArgs(1),
 	Short:   "Rename a file or a directory of files using various utilities.",
-	Example: "glow file rename --extensions \"mp4,png\" --startsWith \"abc\" --endsWith \"123\" --replace \"abc\" --to \"\"\nglow file rename --iterate number --to \"BOGUS VOLUME {}\" --toTitle",
+	Example: "glow file rename --extensions \"mp4,png\" --startsWith \"abc\" --endsWith \"123\" --replace \"abc\" --to \"\"\nglow file rename --iterate number --to \"BOGUS VOLUME {}\" --toTitle\nshelf rename --regex \"(\\d{4})-(\\d{2})-(\\d{2})\" --to \"$3.$2.$1\"",
 	Long:    ``,
 	Run:     runRename,
 }
 
 func runRename(cmd *cobra.Command, args []string) {
 	// Tools
-	cwd := common.GetCwd()
-	if revert, _ := cmd.Flags().GetBool("revert"); revert {
-		revertLastChanges()
+	root := common.GetCwd()
+	if len(args) > 0 {
+		path, err := filepath.Abs(args[0])
+		if err != nil {
+			fmt.Println("Couldn't find the given path:", err)
+			return
+		}
+		root = path
+	}
+
+	if !setNameProfile(cmd) {
+		return
+	}
+
+	if history, _ := cmd.Flags().GetBool("history"); history {
+		printHistory(root)
+		return
+	}
+
+	if undo, _ := cmd.Flags().GetInt("undo"); undo > 0 {
+		undoRenames(root, undo)
+		return
+	} else if revert, _ := cmd.Flags().GetBool("revert"); revert {
+		undoRenames(root, 1)
+		return
+	}
+
+	if redo, _ := cmd.Flags().GetBool("redo"); redo {
+		redoRenames(root)
+		return
+	}
+
+	if mapPath, _ := cmd.Flags().GetString("from-map"); mapPath != "" {
+		mapping, err := readMapping(mapPath)
+		if err != nil {
+			color.Red("%v", err)
+			return
+		}
+
+		// Mappings exported by shelf know their folder, an explicit path still wins
+		if len(args) == 0 && mapping.Path != "" {
+			root = mapping.Path
+		}
+
+		originalFiles, changedFiles, err := mappingNames(root, mapping)
+		if err != nil {
+			color.Red("%v", err)
+			return
+		}
+
+		commitRenames(cmd, root, originalFiles, changedFiles)
 		return
 	}
 
-	files := common.ReadFiles(cwd)
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	dirs, _ := cmd.Flags().GetBool("dirs")
+	files := common.ReadEntries(root, recursive, dirs)
 	// Selectors
 	if contains, _ := cmd.Flags().GetString("contains"); contains != "" {
 		files = filterFiles(files, func(name string) bool {
@@ -68,6 +119,19 @@ func runRename(cmd *cobra.Command, args []string) {
 		})
 	}
 
+	ignoreCase, _ := cmd.Flags().GetBool("ignoreCase")
+	if match, _ := cmd.Flags().GetString("match"); match != "" {
+		matcher, err := compileRegex(match, ignoreCase)
+		if err != nil {
+			fmt.Println("'--match' flag is not a valid regular expression:", err)
+			return
+		}
+
+		files = filterFiles(files, func(name string) bool {
+			return matcher.MatchString(common.GetPureFilename(name))
+		})
+	}
+
 	if extensions, _ := cmd.Flags().GetString("extensions"); extensions != "" {
 		extensions = strings.ReplaceAll(extensions, ".", "")
 		extensionsSlice := strings.FieldsFunc(extensions, func(c rune) bool { return c == ',' })
@@ -85,33 +149,118 @@ func runRename(cmd *cobra.Command, args []string) {
 	}
 
 	// Operations
-	changedFiles := getFileNames(files)
-	common.NaturalSort(changedFiles)
-	originalFiles := make([]string, len(changedFiles))
-	copy(originalFiles, changedFiles)
+	sortBy, _ := cmd.Flags().GetString("sort")
+	seed, _ := cmd.Flags().GetInt64("seed")
+	if err := sortFiles(root, files, sortBy, naturalOrder(cmd), seed, cmd.Flags().Changed("seed")); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if reverse, _ := cmd.Flags().GetBool("reverse"); reverse {
+		slices.Reverse(files)
+	}
 
-	toValue, _ := cmd.Flags().GetString("to")
-	if !checkForbiddenRunes(toValue) {
-		fmt.Println("New file name cannot contain the following characters: / \\ : ? * < > | \"")
+	if edit, _ := cmd.Flags().GetBool("edit"); edit {
+		originalFiles, changedFiles, err := editNames(root, files)
+		if err != nil {
+			color.Red("%v", err)
+			return
+		}
+
+		commitRenames(cmd, root, originalFiles, changedFiles)
+		return
+	}
+
+	if fix, _ := cmd.Flags().GetBool("fix-extensions"); fix {
+		originalFiles, changedFiles := fixExtensions(root, files)
+		commitRenames(cmd, root, originalFiles, changedFiles)
 		return
 	}
 
+	// Operations work on the names without the extension, directories have none
+	changedFiles, extensions := splitNames(files)
+
+	toValue, _ := cmd.Flags().GetString("to")
+	exif, _ := cmd.Flags().GetBool("exif")
+	if exif && toValue == "" {
+		toValue = "{date:2006-01-02_15-04-05}"
+	}
 	replace, _ := cmd.Flags().GetString("replace")
-	if random, _ := cmd.Flags().GetBool("random"); random {
-		changedFiles = randomizeFiles(changedFiles)
-	} else if iterate, _ := cmd.Flags().GetString("iterate"); iterate != "" && toValue != "" {
-		if !checkEnum(iterate, iterateEnum) {
+	replaceOnce, _ := cmd.Flags().GetString("replaceOnce")
+	pattern, _ := cmd.Flags().GetString("regex")
+	iterate, _ := cmd.Flags().GetString("iterate")
+	useTemplate := toValue != "" && (iterate != "" || (replace == "" && replaceOnce == "" && pattern == "" && isTemplate(toValue)))
+
+	// Templates are checked after being rendered, their filters use the '|' character
+	if !useTemplate && !checkForbiddenRunes(toValue) {
+		fmt.Println("New file name " + forbiddenRunesMessage())
+		return
+	}
+
+	random, _ := cmd.Flags().GetBool("random")
+	if byHash, _ := cmd.Flags().GetBool("by-hash"); byHash {
+		length, _ := cmd.Flags().GetInt("length")
+		var err error
+		if changedFiles, err = hashFiles(files, length); err != nil {
+			color.Red("%v", err)
+			return
+		}
+	} else if random {
+		options := randomOptions{Seeded: cmd.Flags().Changed("seed")}
+		options.Format, _ = cmd.Flags().GetString("format")
+		options.Length, _ = cmd.Flags().GetInt("length")
+		options.Alphabet, _ = cmd.Flags().GetString("alphabet")
+		options.Seed, _ = cmd.Flags().GetInt64("seed")
+
+		var err error
+		if changedFiles, err = randomizeFiles(files, extensions, options); err != nil {
+			color.Red("%v", err)
+			return
+		}
+	} else if useTemplate {
+		if iterate != "" && !checkEnum(iterate, iterateEnum) {
 			fmt.Println("'--iterate' flag does not contain a valid option.")
 			return
 		}
 
-		if !strings.Contains(toValue, "{}") {
+		template, err := parseTemplate(toValue)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		if iterate != "" && !template.hasToken("n") {
 			fmt.Println("'--to' flag does not contain the '{}' token to be replaced.")
 			return
 		}
 
-		changedFiles = iterateFiles(changedFiles, toValue, iterate)
-	} else if replaceOnce, _ := cmd.Flags().GetString("replaceOnce"); replaceOnce != "" || replace != "" {
+		start, _ := cmd.Flags().GetInt("start")
+		step, _ := cmd.Flags().GetInt("step")
+		pad, _ := cmd.Flags().GetBool("pad")
+		seq, err := newSequence(iterate, start, step, len(files), pad)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		if changedFiles, err = templateFiles(files, template, seq); err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		if template.hasToken("ext") {
+			clear(extensions)
+		} else if exif {
+			extensions = filesToCase(extensions, strings.ToLower)
+		}
+	} else if pattern != "" {
+		expression, err := compileRegex(pattern, ignoreCase)
+		if err != nil {
+			fmt.Println("'--regex' flag is not a valid regular expression:", err)
+			return
+		}
+
+		changedFiles = regexFiles(changedFiles, expression, toValue)
+	} else if replaceOnce != "" || replace != "" {
 		if replaceOnce != "" {
 			changedFiles = replaceFiles(changedFiles, replaceOnce, 1, toValue)
 		} else {
@@ -119,21 +268,86 @@ func runRename(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	if sanitize, _ := cmd.Flags().GetBool("sanitize"); sanitize {
+		maxLength, _ := cmd.Flags().GetInt("maxLength")
+		if maxLength <= 0 {
+			fmt.Println("'--maxLength' flag must be a positive number of bytes.")
+			return
+		}
+		changedFiles, extensions = sanitizeFiles(changedFiles, extensions, maxLength)
+	}
+
 	// String Cases
+	caseStyle, _ := cmd.Flags().GetString("case")
 	if toUpper, _ := cmd.Flags().GetBool("toUpper"); toUpper {
-		changedFiles = filesToCase(changedFiles, strings.ToUpper)
+		caseStyle = "upper"
 	} else if toLower, _ := cmd.Flags().GetBool("toLower"); toLower {
-		changedFiles = filesToCase(changedFiles, strings.ToLower)
+		caseStyle = "lower"
 	} else if toTitle, _ := cmd.Flags().GetBool("toTitle"); toTitle {
-		changedFiles = filesToCase(changedFiles, strings.ToTitle)
+		caseStyle = "title"
+	}
+
+	if caseStyle != "" {
+		if !checkEnum(caseStyle, caseEnum) {
+			fmt.Println("'--case' flag does not contain a valid option.")
+			return
+		}
+
+		// Only the name changes case, the extension stays as it is
+		changedFiles = filesToCase(changedFiles, caseStyles[caseStyle])
+	}
+
+	originalFiles, changedFiles := relativeNames(root, files, joinNames(changedFiles, extensions))
+	commitRenames(cmd, root, originalFiles, changedFiles)
+}
+
+// Plans the renames and either previews or applies them, recording them in the history
+func commitRenames(cmd *cobra.Command, root string, originalFiles []string, changedFiles []string) {
+	policy, _ := cmd.Flags().GetString("conflict")
+	if !checkEnum(policy, conflictEnum) {
+		fmt.Println("'--conflict' flag does not contain a valid option.")
+		return
+	}
+
+	if violations := profileViolations(originalFiles, changedFiles); len(violations) > 0 {
+		color.Red("Some of the new names aren't valid for the '%s' profile:", nameProfile.Name)
+		for _, violation := range violations {
+			color.Red("\t%s", violation)
+		}
+		color.Yellow("No files were renamed. Change the operations or pick another '--profile'.")
+		return
+	}
+
+	plan, err := planRenames(originalFiles, root, changedFiles, conflictPolicy(policy))
+	if exportPath, _ := cmd.Flags().GetString("export-map"); exportPath != "" && plan != nil {
+		if err := writeMapping(exportPath, plan); err != nil {
+			color.Red("Couldn't export the mapping: %v", err)
+		} else if exportPath != "-" {
+			color.Green("Mapping of %d files saved to %s.", len(plan.Entries), exportPath)
+		}
+		return
+	}
+
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun && plan != nil {
+		previewRenames(plan)
+		return
 	}
 
-	if changesWereMade(originalFiles, changedFiles) {
-		makeRecord(originalFiles, cwd, changedFiles)
-		renameFiles(originalFiles, cwd, changedFiles)
-	} else {
+	if err != nil {
+		printConflicts(plan, err)
+		return
+	}
+
+	if len(plan.Steps) == 0 {
 		fmt.Println(color.YellowString("No changes were made. If that's not intentional, check your filters, operations and try again."))
+		return
 	}
+
+	if err := plan.apply(); err != nil {
+		color.Red("An error occured while trying to rename the files: %v", err)
+		return
+	}
+	makeRecord(plan)
 }
 
 // Initialize the command
@@ -142,85 +356,168 @@ func init() {
 	RenameCmd.Flags().String("contains", "", "Selects all files which contains the given literal.")
 	RenameCmd.Flags().String("startsWith", "", "Selects all files which starts with the given literal.")
 	RenameCmd.Flags().String("endsWith", "", "Selects all files which ends with the given literal (excluding the file extension).")
+	RenameCmd.Flags().String("match", "", "Selects all files which match the given regular expression (excluding the file extension).")
 	RenameCmd.Flags().String("extensions", "", "Selects files by the given pool of file extensions. (separated by comma)")
+	RenameCmd.Flags().BoolP("recursive", "R", false, "Selects files in all subdirectories of the path too.")
+	RenameCmd.Flags().Bool("dirs", false, "Selects directories too, renaming them after their contents.")
 
 	// Operations
-	RenameCmd.Flags().String("iterate", "", "Type of value to append to '--to' flag (number, letter, mixed), '--to' must have {} to be replaced by the value.")
+	RenameCmd.Flags().String("iterate", "", "Type of value to append to '--to' flag (number, letter, mixed), '--to' must have {} to be replaced by the value. 'mixed' counts with digits and letters (0-9, a-z).")
+	RenameCmd.Flags().Int("start", 1, "First value of the '--iterate' sequence (1 is 'a' for letters).")
+	RenameCmd.Flags().Int("step", 1, "Increment between the values of the '--iterate' sequence, can be negative.")
+	RenameCmd.Flags().Bool("pad", false, "Zero-pads the '--iterate' values to the width of the biggest one. (01, 02 ... 10)")
+	RenameCmd.Flags().String("sort", "natural", "Order the selected files are numbered in (name, natural, mtime, ctime, size, exif-date, random). Times go oldest first with the files without a capture date last, sizes go smallest first. 'ctime' is the creation time on Windows and macOS and the last status change elsewhere.")
+	RenameCmd.Flags().Bool("caseSensitive", false, "Natural order sorts upper case names before lower case ones instead of ignoring the case.")
+	RenameCmd.Flags().Bool("accentSensitive", false, "Natural order sorts accented letters after 'z' instead of with their base letter.")
+	RenameCmd.Flags().Bool("decimals", false, "Natural order reads digits after a dot as a fraction, so '1.5' comes after '1.10'.")
+	RenameCmd.Flags().Bool("versions", false, "Natural order puts pre-releases before their release, so '1.0-rc1' comes before '1.0'.")
+	RenameCmd.Flags().Bool("reverse", false, "Numbers the selected files in reverse order.")
 	RenameCmd.Flags().BoolP("random", "r", false, "Renames all selected files to a random string of characters and numbers.")
+	RenameCmd.Flags().Bool("exif", false, "Renames photos (JPEG, TIFF, HEIC) after their EXIF capture date, like 2024-06-01_14-22-05.jpg, falling back to the modification time. '--to' can set another format with {date:layout} and {camera}.")
+	RenameCmd.Flags().Int64("seed", 0, "Seed for '--random' and '--sort random', the same seed gives the same files the same names and order.")
+	RenameCmd.Flags().Int("length", 0, "Length of the '--random' names (35 by default) or of the '--by-hash' digests (the whole 64 by default).")
+	RenameCmd.Flags().String("alphabet", defaultAlphabet, "Characters the '--random' names are made of.")
+	RenameCmd.Flags().String("format", "chars", "Format of the '--random' names: 'chars' from the '--alphabet', 'uuid' (version 4) or 'ulid' (sorted by modification time).")
+	RenameCmd.Flags().Bool("by-hash", false, "Renames the files to the SHA-256 of their contents, so the same file always gets the same name.")
 	RenameCmd.Flags().String("replace", "", "Replace all instances of the given expression, if found. (--to flag is required)")
 	RenameCmd.Flags().String("replaceOnce", "", "Replace first instance of the given expression, if found. (--to flag is required)")
-	RenameCmd.Flags().String("to", "", "The value to replace, or the name to be set.")
+	RenameCmd.Flags().String("regex", "", "Replace all matches of the given regular expression, '--to' can reference capture groups as $1 or ${name}. (--to flag is required)")
+	RenameCmd.Flags().Bool("ignoreCase", false, "Makes '--regex' and '--match' case-insensitive.")
+	RenameCmd.Flags().String("to", "", "The value to replace, or the name to be set. Names can be templates using {n:03}, {name}, {ext}, {parent}, {mtime:2006-01-02}, {size}, {hash:8}, {date}, {camera}, {artist}, {album}, {title}, {track:02} and filters like {name|upper} or {name|slug}.")
+	RenameCmd.Flags().Bool("sanitize", false, "Cleans the names after the other operations: transliterates to ASCII (ç to c, ß to ss, Cyrillic and Greek to Latin), removes emoji and control characters, collapses spaces and repeated separators and trims trailing dots and spaces.")
+	RenameCmd.Flags().Int("maxLength", 255, "Maximum length in bytes of the '--sanitize' names, the extension is kept.")
 
 	// String Cases
+	RenameCmd.Flags().String("case", "", "Converts the names of the selected files, keeping their extensions (upper, lower, title, sentence, snake, kebab, camel, pascal, slug)")
 	RenameCmd.Flags().Bool("toUpper", false, "Flips all selected files to Upper Case (after all replace and rename operations)")
 	RenameCmd.Flags().Bool("toLower", false, "Flips all selected files to Lower Case (after all replace and rename operations)")
 	RenameCmd.Flags().Bool("toTitle", false, "Flips all selected files to Title Case (after all replace and rename operations)")
 
 	// Tools
-	RenameCmd.Flags().Bool("revert", false, "Revert the last rename operation in the current folder, if any.")
+	RenameCmd.Flags().Bool("revert", false, "Revert the last rename operation in the current folder, if any. (same as '--undo 1')")
+	RenameCmd.Flags().Int("undo", 0, "Revert the last N rename operations in the current folder, newest first.")
+	RenameCmd.Flags().Bool("redo", false, "Reapply the last reverted rename operation in the current folder.")
+	RenameCmd.Flags().Bool("history", false, "List the rename operations made in the current folder.")
+	RenameCmd.Flags().Bool("edit", false, "Opens the selected filenames in $EDITOR, one per line, and renames the files to the edited lines.")
+	RenameCmd.Flags().Bool("fix-extensions", false, "Gives the selected files the extension of their contents (a PNG saved as .jpg or .bin becomes .png) and normalizes the others (.JPEG to .jpg, .tif to .tiff).")
+	RenameCmd.Flags().String("from-map", "", "Renames the files listed in a CSV (originalName,changedName) or JSON mapping file, relative to the path.")
+	RenameCmd.Flags().String("export-map", "", "Saves the planned (or current) names of the selected files to a CSV or JSON mapping file instead of renaming them. Use '-' to print it.")
+	RenameCmd.Flags().String("profile", "basic", "Set of file system rules the new names must follow (basic, posix, windows, macos, portable). 'basic' only forbids the characters no common file system accepts.")
+	RenameCmd.Flags().String("conflict", "abort", "What to do when a new name is already taken (abort, skip, suffix, overwrite). 'suffix' appends \" (n)\" to the name.")
+	RenameCmd.Flags().Bool("dry-run", false, "Prints the old -> new names of the selected files without renaming anything.")
 }
 
-// Checks if any files were renamed in the process
-func changesWereMade(originalFiles []string, changedFiles []string) bool {
-	for index := range originalFiles {
-		if originalFiles[index] != changedFiles[index] {
-			return true
-		}
+// Replace the filename with the literal given
+func replaceFiles(files []string, literal string, numChanges int, replace string) []string {
+	for index := range files {
+		files[index] = strings.Trim(strings.Replace(files[index], literal, replace, numChanges), " ")
 	}
-	return false
+
+	return files
 }
 
-// Save records of the renaming operations
-func saveRecords(records renameOperation) {
-	recordsBytes, err := json.Marshal(records)
-	if err != nil {
-		panic(err)
+// Replace the matches of the expression in the filename, expanding capture groups in the replacement
+func regexFiles(files []string, expression *regexp.Regexp, replace string) []string {
+	for index := range files {
+		files[index] = strings.Trim(expression.ReplaceAllString(files[index], replace), " ")
 	}
 
-	err = os.WriteFile(filepath.Join(common.GetExePath(), "rename_operations.json"), recordsBytes, 0644)
-	if err != nil {
-		panic(err)
+	return files
+}
+
+// Compiles a user given regular expression
+func compileRegex(pattern string, ignoreCase bool) (*regexp.Regexp, error) {
+	if ignoreCase {
+		pattern = "(?i)" + pattern
 	}
+	return regexp.Compile(pattern)
 }
 
-// Create and saves the save records of the renamed files
-func makeRecord(originalFiles []string, cwd string, changedFiles []string) {
-	records := make([]operationValues, len(originalFiles))
+// Render the '--to' template for each file, the caller keeps the original extension unless the template uses {ext}
+func templateFiles(files []common.FileStats, template *nameTemplate, seq *sequence) ([]string, error) {
+	filenames := make([]string, len(files))
+	for index, file := range files {
+		name, err := template.render(&templateContext{File: file, Index: index, Sequence: seq})
+		if err != nil {
+			return nil, err
+		}
 
-	for index := range records {
-		records[index] = operationValues{OriginalName: originalFiles[index], ChangedName: changedFiles[index]}
+		name = strings.Trim(name, " ")
+		if !checkForbiddenRunes(name) {
+			return nil, fmt.Errorf("the new name '%s' %s", name, forbiddenRunesMessage())
+		}
+		filenames[index] = name
 	}
 
-	saveRecords(renameOperation{
-		Path:       cwd,
-		Operations: records,
-	})
+	return filenames, nil
 }
 
-// Replace the filename with the literal given
-func replaceFiles(files []string, literal string, numChanges int, replace string) []string {
-	for index := range files {
-		files[index] = strings.Trim(strings.Replace(common.GetPureFilename(files[index]), literal, replace, numChanges)+common.GetFileExtension(files[index]), " ")
+// Split the filenames in names without the extension and extensions, directories keep their whole name
+func splitNames(files []common.FileStats) (names []string, extensions []string) {
+	names, extensions = make([]string, len(files)), make([]string, len(files))
+	for index, file := range files {
+		if file.Info.IsDir() {
+			names[index] = file.Filename
+		} else {
+			filename := common.ParseFilename(file.Filename)
+			names[index], extensions[index] = filename.Stem, filename.Extension
+		}
 	}
+	return
+}
 
-	return files
+// Join the names back with their extensions
+func joinNames(names []string, extensions []string) []string {
+	filenames := make([]string, len(names))
+	for index := range names {
+		filenames[index] = names[index] + extensions[index]
+	}
+	return filenames
 }
 
-// Replace all occurances in the filename
-func iterateFiles(files []string, toValue string, iterate string) []string {
-	for index := range files {
-		files[index] = strings.Trim(strings.ReplaceAll(toValue, "{}", fmt.Sprint(index+1))+common.GetFileExtension(files[index]), " ")
+// Get the old and new names of the files relative to the root, deepest first
+func relativeNames(root string, files []common.FileStats, changedFiles []string) (originals []string, changed []string) {
+	for index, file := range files {
+		original := relativePath(root, file.Path)
+		originals = append(originals, original)
+		changed = append(changed, filepath.Join(filepath.Dir(original), changedFiles[index]))
 	}
 
-	return files
+	sortDeepestFirst(originals, changed)
+	return
 }
 
-// Get the filename of the given directory of files
-func getFileNames(files []common.FileStats) (filenames []string) {
-	for _, file := range files {
-		filenames = append(filenames, file.Info.Name())
+// Sorts the renames so the deepest paths come first, renaming a directory never invalidates the path of
+// a file renamed after it
+func sortDeepestFirst(originals []string, changed []string) {
+	order := make([]int, len(originals))
+	for index := range order {
+		order[index] = index
 	}
-	return
+	sort.SliceStable(order, func(a, b int) bool {
+		return pathDepth(originals[order[a]]) > pathDepth(originals[order[b]])
+	})
+
+	sortedOriginals, sortedChanged := make([]string, len(order)), make([]string, len(order))
+	for position, index := range order {
+		sortedOriginals[position], sortedChanged[position] = originals[index], changed[index]
+	}
+	copy(originals, sortedOriginals)
+	copy(changed, sortedChanged)
+}
+
+// Path of a file relative to the root of the rename
+func relativePath(root string, path string) string {
+	relative, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return relative
+}
+
+// Number of directories above a relative path
+func pathDepth(path string) int {
+	return strings.Count(path, string(os.PathSeparator))
 }
 
 // Check if a enum is valid
@@ -233,30 +530,6 @@ func checkEnum(a string, list []string) bool {
 	return false
 }
 
-// Read the rename_operations.json file, if it exists and revert its operations
-func revertLastChanges() {
-	reverter := renameOperation{}
-
-	filebytes, err := os.ReadFile(filepath.Join(common.GetExePath(), "rename_operations.json"))
-	if err != nil {
-		panic(err)
-	}
-
-	err = json.Unmarshal(filebytes, &reverter)
-	if err != nil {
-		fmt.Println("An error occured while reading the renaming operations history.")
-	}
-
-	source := make([]string, len(reverter.Operations))
-	modified := make([]string, len(reverter.Operations))
-	for index, value := range reverter.Operations {
-		modified[index] = value.OriginalName
-		source[index] = value.ChangedName
-	}
-
-	renameFiles(source, reverter.Path, modified)
-}
-
 // Rename the files given a func to morph, intended to put a case in the name
 func filesToCase(files []string, toCase func(string) string) (filenames []string) {
 	for _, file := range files {
@@ -276,51 +549,81 @@ func filterFiles(files []common.FileStats, filter func(string) bool) (filtered [
 	return filtered
 }
 
-// Give randomized filenames to the directory
-func randomizeFiles(files []string) (changedFiles []string) {
-	for i := 0; i < len(files); i++ {
-		changedFiles = append(changedFiles, randomName()+common.GetFileExtension(files[i]))
+// Commits the changed names and rename the files, checking for same-name files first
+func renameFiles(files []string, path string, newFiles []string, policy conflictPolicy) (*renamePlan, error) {
+	plan, err := planRenames(files, path, newFiles, policy)
+	if err != nil {
+		return plan, err
 	}
 
-	return
+	return plan, plan.apply()
 }
 
-// Create a randomized filename with a fixed length
-func randomName() string {
-	strong := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	alfabet := []rune(strong)
-	name := make([]rune, 35)
-	bound := len(strong)
-	for i := 0; i < 35; i++ {
-		name[i] = alfabet[rand.Intn(bound)]
+// Lists the renames that couldn't be resolved by the conflict policy
+func printConflicts(plan *renamePlan, err error) {
+	color.Red("Couldn't rename the files: %v", err)
+	if plan == nil {
+		return
 	}
 
-	return string(name)
-}
+	for _, entry := range plan.Entries {
+		if !entry.unresolved() {
+			continue
+		}
 
-// Commits the changed names and rename the files
-func renameFiles(files []string, path string, newFiles []string) {
-	// TODO: Check if in the newFiles has a same-name file
-	if len(files) != len(newFiles) {
-		log.Fatal("The number of files in the list are not equal, cannot map old filenames to new filenames")
+		if entry.Conflict == conflictExists {
+			color.Red("\t%s -> %s (a file with this name already exists)", entry.Original, entry.Requested)
+		} else {
+			color.Red("\t%s -> %s (collides with another rename)", entry.Original, entry.Requested)
+		}
 	}
+	color.Yellow("No files were renamed. Use '--conflict skip|suffix|overwrite' to resolve the conflicts, or '--dry-run' to review them.")
+}
 
-	for index, file := range files {
-		err := os.Rename(filepath.Join(path, file), filepath.Join(path, newFiles[index]))
-		if err != nil {
-			fmt.Println("An error occured while trying to rename the files: ", err)
+// Checks if a name has forbidden characters for the file systems of the profile, path separators included
+func checkForbiddenRunes(name string) (sentinel bool) {
+	return !strings.ContainsAny(name, "/"+string(os.PathSeparator)) && len(nameProfile.ForbiddenIn(name)) == 0
+}
+
+// Explains which characters the profile forbids
+func forbiddenRunesMessage() string {
+	runes := []string{"/"}
+	for _, char := range nameProfile.ForbiddenRunes {
+		if char != '/' {
+			runes = append(runes, string(char))
 		}
 	}
+
+	message := "cannot contain the following characters: " + strings.Join(runes, " ")
+	if nameProfile.ControlChars {
+		message += " (or control characters)"
+	}
+	return message
 }
 
-// Checks if a name has forbidden characters for the file systems
-func checkForbiddenRunes(name string) (sentinel bool) {
-	sentinel = true
-	for _, runer := range blacklist {
-		if strings.ContainsRune(name, runer) {
-			sentinel = false
-			break
+// Selects the name validation profile given by the '--profile' flag
+func setNameProfile(cmd *cobra.Command) bool {
+	name, _ := cmd.Flags().GetString("profile")
+	profile, ok := common.NameProfiles[name]
+	if !ok {
+		fmt.Printf("'--profile' flag does not contain a valid option (%s).\n", strings.Join(common.NameProfileNames(), ", "))
+		return false
+	}
+
+	nameProfile = profile
+	return true
+}
+
+// Lists the new names that the profile doesn't allow, empty if all of them are valid
+func profileViolations(originalFiles []string, changedFiles []string) (violations []string) {
+	for index, changed := range changedFiles {
+		if changed == originalFiles[index] {
+			continue
+		}
+
+		for _, violation := range nameProfile.Check(filepath.Base(changed)) {
+			violations = append(violations, fmt.Sprintf("%s %s", changed, violation))
 		}
 	}
-	return sentinel
+	return violations
 }
@@ -0,0 +1,60 @@
+package file
+
+import (
+	"shelf/common"
+	"strings"
+	"unicode"
+)
+
+// Makes the names safe for any tool: ASCII letters where possible, no emoji, control or forbidden characters,
+// single separators, no trailing dots or spaces and at most maxLength bytes with the extension
+func sanitizeFiles(names []string, extensions []string, maxLength int) ([]string, []string) {
+	sanitized, sanitizedExtensions := make([]string, len(names)), make([]string, len(extensions))
+	for index, name := range names {
+		extension := sanitizeText(extensions[index])
+		name = trimName(sanitizeText(name))
+
+		runes := []rune(name)
+		for len(runes) > 0 && len(string(runes))+len(extension) > maxLength {
+			runes = runes[:len(runes)-1]
+		}
+		name = trimName(string(runes))
+
+		if name == "" {
+			name = "_"
+		}
+		sanitized[index], sanitizedExtensions[index] = name, extension
+	}
+	return sanitized, sanitizedExtensions
+}
+
+// Transliterates a text, drops the characters no name should have and collapses repeated separators
+func sanitizeText(text string) string {
+	sanitized := strings.Builder{}
+	var last rune
+	for _, char := range common.Transliterate(text) {
+		switch {
+		case unicode.IsSpace(char):
+			char = ' '
+		case unicode.IsControl(char), unicode.In(char, unicode.Cf, unicode.Co, unicode.So, unicode.Mn):
+			continue
+		case char > unicode.MaxASCII && unicode.Is(unicode.Sk, char):
+			// Emoji skin tones
+			continue
+		case !checkForbiddenRunes(string(char)):
+			char = '_'
+		}
+
+		if char == last && strings.ContainsRune(" _-.", char) {
+			continue
+		}
+		sanitized.WriteRune(char)
+		last = char
+	}
+	return sanitized.String()
+}
+
+// Removes the spaces around a name and the dots after it, which some file systems drop silently
+func trimName(name string) string {
+	return strings.TrimRight(strings.TrimSpace(name), ". ")
+}
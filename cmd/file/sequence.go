@@ -0,0 +1,77 @@
+package file
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// The values given to the files of a batch when iterating, like 1, 2, 3 or a, b, c
+type sequence struct {
+	Mode  string
+	Start int
+	Step  int
+	Width int
+}
+
+// Creates the sequence for a batch, when padded every value has the width of the widest one
+func newSequence(mode string, start int, step int, count int, pad bool) (*sequence, error) {
+	if mode == "" {
+		mode = "number"
+	}
+	if step == 0 {
+		return nil, errors.New("'--step' flag cannot be zero")
+	}
+
+	seq := &sequence{Mode: mode, Start: start, Step: step}
+	if count > 0 && (mode == "letter" || mode == "mixed") {
+		if last := start + (count-1)*step; start < 0 || last < 0 || (mode == "letter" && (start == 0 || last == 0)) {
+			return nil, errors.New("letter and mixed sequences cannot go below 'a' or '0', check '--start' and '--step'")
+		}
+	}
+
+	if pad && count > 0 {
+		seq.Width = max(len(seq.format(start)), len(seq.format(start+(count-1)*step)))
+	}
+	return seq, nil
+}
+
+// The value of the nth file of the batch, padded to the given width
+func (seq *sequence) value(index int, width int) string {
+	return seq.pad(seq.format(seq.Start+index*seq.Step), width)
+}
+
+// Formats a number in the mode of the sequence
+func (seq *sequence) format(number int) string {
+	switch seq.Mode {
+	case "letter":
+		return toLetters(number)
+	case "mixed":
+		return strconv.FormatInt(int64(number), 36)
+	default:
+		return strconv.Itoa(number)
+	}
+}
+
+// Zero pads a value, letters have no zero so they're never padded
+func (seq *sequence) pad(value string, width int) string {
+	if seq.Mode == "letter" {
+		return value
+	}
+
+	if strings.HasPrefix(value, "-") {
+		return "-" + padLeft(value[1:], width-1, '0')
+	}
+	return padLeft(value, width, '0')
+}
+
+// Converts a number to spreadsheet-like letters: 1 is a, 26 is z, 27 is aa
+func toLetters(number int) string {
+	letters := []byte{}
+	for number > 0 {
+		number--
+		letters = append([]byte{byte('a' + number%26)}, letters...)
+		number /= 26
+	}
+	return string(letters)
+}
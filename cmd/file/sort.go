@@ -0,0 +1,88 @@
+package file
+
+import (
+	"errors"
+	"math/rand"
+	"shelf/common"
+	"shelf/metadata"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var sortEnum []string = []string{"name", "natural", "mtime", "ctime", "size", "exif-date", "random"}
+
+// Orders the files before they are numbered or edited: by name, by natural order (file2 before file10), oldest first,
+// smallest first or shuffled. Files with the same key stay in natural order.
+func sortFiles(root string, files []common.FileStats, by string, natural common.SortOptions, seed int64, seeded bool) error {
+	if !checkEnum(by, sortEnum) {
+		return errors.New("'--sort' flag does not contain a valid option")
+	}
+
+	common.SortBy(files, func(file common.FileStats) string { return relativePath(root, file.Path) }, natural)
+
+	switch by {
+	case "name":
+		sort.SliceStable(files, func(a, b int) bool {
+			return relativePath(root, files[a].Path) < relativePath(root, files[b].Path)
+		})
+	case "mtime":
+		sortByTime(files, func(file common.FileStats) time.Time { return file.Info.ModTime() })
+	case "ctime":
+		sortByTime(files, func(file common.FileStats) time.Time { return common.CreationTime(file.Info) })
+	case "exif-date":
+		sortByTime(files, captureTime)
+	case "size":
+		sort.SliceStable(files, func(a, b int) bool {
+			return files[a].Info.Size() < files[b].Info.Size()
+		})
+	case "random":
+		if !seeded {
+			seed = time.Now().UnixNano()
+		}
+		rand.New(rand.NewSource(seed)).Shuffle(len(files), func(a, b int) {
+			files[a], files[b] = files[b], files[a]
+		})
+	}
+	return nil
+}
+
+// The natural order given by the '--caseSensitive', '--accentSensitive', '--decimals' and '--versions' flags
+func naturalOrder(cmd *cobra.Command) common.SortOptions {
+	options := common.FileNameOrder
+	if caseSensitive, _ := cmd.Flags().GetBool("caseSensitive"); caseSensitive {
+		options.FoldCase = false
+	}
+	if accentSensitive, _ := cmd.Flags().GetBool("accentSensitive"); accentSensitive {
+		options.Collate = false
+	}
+	options.Decimals, _ = cmd.Flags().GetBool("decimals")
+	options.Versions, _ = cmd.Flags().GetBool("versions")
+	return options
+}
+
+// Sorts the files oldest first, reading each time only once. Files without a time go last.
+func sortByTime(files []common.FileStats, timeOf func(common.FileStats) time.Time) {
+	times := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		times[file.Path] = timeOf(file)
+	}
+
+	sort.SliceStable(files, func(a, b int) bool {
+		timeA, timeB := times[files[a].Path], times[files[b].Path]
+		if timeA.IsZero() || timeB.IsZero() {
+			return !timeA.IsZero() && timeB.IsZero()
+		}
+		return timeA.Before(timeB)
+	})
+}
+
+// Capture date of a photo, or no date if it has none or its metadata can't be read
+func captureTime(file common.FileStats) time.Time {
+	exif, err := metadata.ReadExif(file.Path)
+	if err != nil {
+		return time.Time{}
+	}
+	return exif.DateTimeOriginal
+}
@@ -0,0 +1,58 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"shelf/common"
+	"slices"
+	"testing"
+	"time"
+)
+
+// A little endian TIFF whose IFD0 only holds the date
+func datedTIFF(date string) []byte {
+	return append([]byte("II*\x00\x08\x00\x00\x00"+
+		"\x01\x00"+
+		"\x32\x01\x02\x00\x14\x00\x00\x00\x1A\x00\x00\x00"+
+		"\x00\x00\x00\x00"), date+"\x00"...)
+}
+
+func TestSortByCaptureTime(t *testing.T) {
+	root := t.TempDir()
+	contents := map[string][]byte{
+		// A video cut right after its file type box
+		"a.mp4":  []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00iso"),
+		"b.tif":  datedTIFF("2020:01:01 10:00:00"),
+		"c.txt":  []byte("no metadata"),
+		"d.tif":  datedTIFF("2019:06:01 10:00:00"),
+		"e.heic": []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1\x00\x00\x01\x00meta"),
+	}
+
+	old := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []common.FileStats{}
+	for name, content := range contents {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, common.FileStats{Info: info, Path: path, Filename: name})
+	}
+
+	if err := sortFiles(root, files, "exif-date", common.FileNameOrder, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, file := range files {
+		names = append(names, file.Filename)
+	}
+	if want := []string{"d.tif", "b.tif", "a.mp4", "c.txt", "e.heic"}; !slices.Equal(names, want) {
+		t.Errorf("sortFiles() = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,353 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"shelf/common"
+	"shelf/metadata"
+	"strconv"
+	"strings"
+)
+
+// Everything a template token may need to know about the file being renamed
+type templateContext struct {
+	File     common.FileStats
+	Index    int
+	Sequence *sequence
+	digest   string
+	exif     *metadata.Exif
+	tags     *metadata.AudioTags
+}
+
+// Resolves a token like {mtime:2006-01-02} given its argument
+type tokenResolver func(ctx *templateContext, arg string) (string, error)
+
+var (
+	templateTokens = map[string]tokenResolver{
+		"n":      numberToken,
+		"name":   nameToken,
+		"ext":    extensionToken,
+		"parent": parentToken,
+		"mtime":  mtimeToken,
+		"size":   sizeToken,
+		"hash":   hashToken,
+		"date":   dateToken,
+		"camera": cameraToken,
+		"artist": artistToken,
+		"album":  albumToken,
+		"title":  titleToken,
+		"track":  trackToken,
+	}
+	templateFilters = map[string]func(string) string{
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+		"title":    titleCase,
+		"sentence": sentenceCase,
+		"snake":    snakeCase,
+		"kebab":    kebabCase,
+		"camel":    camelCase,
+		"pascal":   pascalCase,
+		"slug":     slugCase,
+		"trim":     strings.TrimSpace,
+	}
+)
+
+// A piece of a template, either literal text or a token with its filters
+type templatePart struct {
+	Literal string
+	Token   string
+	Arg     string
+	Filters []string
+}
+
+// A parsed '--to' template, like "{n:03} - {name|upper}"
+type nameTemplate struct {
+	Parts []templatePart
+}
+
+// Parses a template, "{}" is the same as "{n}" and "{{" or "}}" escape the braces
+func parseTemplate(template string) (*nameTemplate, error) {
+	parsed := &nameTemplate{}
+	literal := strings.Builder{}
+
+	for index := 0; index < len(template); index++ {
+		char := template[index]
+		if (char == '{' || char == '}') && index+1 < len(template) && template[index+1] == char {
+			literal.WriteByte(char)
+			index++
+			continue
+		}
+
+		if char == '}' {
+			return nil, errors.New("unexpected '}' in '--to', use '}}' for a literal brace")
+		}
+		if char != '{' {
+			literal.WriteByte(char)
+			continue
+		}
+
+		end := strings.IndexByte(template[index:], '}')
+		if end < 0 {
+			return nil, errors.New("unclosed '{' in '--to', use '{{' for a literal brace")
+		}
+
+		part, err := parseToken(template[index+1 : index+end])
+		if err != nil {
+			return nil, err
+		}
+
+		if literal.Len() > 0 {
+			parsed.Parts = append(parsed.Parts, templatePart{Literal: literal.String()})
+			literal.Reset()
+		}
+		parsed.Parts = append(parsed.Parts, part)
+		index += end
+	}
+
+	if literal.Len() > 0 {
+		parsed.Parts = append(parsed.Parts, templatePart{Literal: literal.String()})
+	}
+	return parsed, nil
+}
+
+// Parses the inside of a "{token:arg|filter}" block
+func parseToken(block string) (templatePart, error) {
+	filters := strings.Split(block, "|")
+	token, arg, _ := strings.Cut(strings.TrimSpace(filters[0]), ":")
+	if token == "" {
+		token = "n"
+	}
+
+	if _, ok := templateTokens[token]; !ok {
+		return templatePart{}, fmt.Errorf("unknown token '{%s}' in '--to'", token)
+	}
+
+	part := templatePart{Token: token, Arg: arg}
+	for _, filter := range filters[1:] {
+		filter = strings.TrimSpace(filter)
+		if _, ok := templateFilters[filter]; !ok {
+			return templatePart{}, fmt.Errorf("unknown filter '|%s' in '--to'", filter)
+		}
+		part.Filters = append(part.Filters, filter)
+	}
+	return part, nil
+}
+
+// Checks if a string uses any template token, escaped braces don't count
+func isTemplate(value string) bool {
+	parsed, err := parseTemplate(value)
+	if err != nil {
+		return strings.Contains(value, "{")
+	}
+	return parsed.hasToken("")
+}
+
+// Checks if the template uses the given token, or any token if empty
+func (template *nameTemplate) hasToken(token string) bool {
+	for _, part := range template.Parts {
+		if part.Token != "" && (token == "" || part.Token == token) {
+			return true
+		}
+	}
+	return false
+}
+
+// Builds the name of a file from the template
+func (template *nameTemplate) render(ctx *templateContext) (string, error) {
+	name := strings.Builder{}
+	for _, part := range template.Parts {
+		if part.Token == "" {
+			name.WriteString(part.Literal)
+			continue
+		}
+
+		value, err := templateTokens[part.Token](ctx, part.Arg)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", ctx.File.Filename, err)
+		}
+		for _, filter := range part.Filters {
+			value = templateFilters[filter](value)
+		}
+		name.WriteString(value)
+	}
+	return name.String(), nil
+}
+
+// {n} or {n:03}, the value of the file in the '--iterate' sequence, zero padded to the given width
+func numberToken(ctx *templateContext, arg string) (string, error) {
+	if arg == "" {
+		return ctx.Sequence.value(ctx.Index, ctx.Sequence.Width), nil
+	}
+
+	width, err := strconv.Atoi(arg)
+	if err != nil || width < 0 {
+		return "", fmt.Errorf("'{n:%s}' must be a number of digits, like '{n:03}'", arg)
+	}
+	return ctx.Sequence.value(ctx.Index, width), nil
+}
+
+// {name}, the original name without the extension, or the whole name of a folder
+func nameToken(ctx *templateContext, arg string) (string, error) {
+	if ctx.File.Info.IsDir() {
+		return ctx.File.Filename, nil
+	}
+	return common.GetPureFilename(ctx.File.Filename), nil
+}
+
+// {ext}, the original extension without the dot, folders have none
+func extensionToken(ctx *templateContext, arg string) (string, error) {
+	if ctx.File.Info.IsDir() {
+		return "", nil
+	}
+	return strings.TrimPrefix(common.GetFileExtension(ctx.File.Filename), "."), nil
+}
+
+// {parent}, the name of the folder holding the file
+func parentToken(ctx *templateContext, arg string) (string, error) {
+	return filepath.Base(filepath.Dir(ctx.File.Path)), nil
+}
+
+// {mtime} or {mtime:2006-01-02}, the modification time in a Go time layout
+func mtimeToken(ctx *templateContext, arg string) (string, error) {
+	if arg == "" {
+		arg = "2006-01-02"
+	}
+	return ctx.File.Info.ModTime().Format(arg), nil
+}
+
+// {size} in bytes, or {size:h} for a human readable size
+func sizeToken(ctx *templateContext, arg string) (string, error) {
+	size := ctx.File.Info.Size()
+	switch arg {
+	case "":
+		return strconv.FormatInt(size, 10), nil
+	case "h":
+		return strings.ReplaceAll(common.FormatBytes(size), " ", ""), nil
+	default:
+		return "", fmt.Errorf("'{size:%s}' is not a valid size format, use '{size}' or '{size:h}'", arg)
+	}
+}
+
+// {hash} or {hash:8}, the first characters of the SHA-256 of the file contents
+func hashToken(ctx *templateContext, arg string) (string, error) {
+	length := 8
+	if arg != "" {
+		var err error
+		if length, err = strconv.Atoi(arg); err != nil || length <= 0 {
+			return "", fmt.Errorf("'{hash:%s}' must be a number of characters, like '{hash:8}'", arg)
+		}
+	}
+
+	if ctx.digest == "" {
+		digest, err := fileDigest(ctx.File.Path)
+		if err != nil {
+			return "", err
+		}
+		ctx.digest = digest
+	}
+	return ctx.digest[:min(length, len(ctx.digest))], nil
+}
+
+// {date} or {date:2006-01-02_15-04-05}, the EXIF capture date of a photo, or its modification time if it has none
+func dateToken(ctx *templateContext, arg string) (string, error) {
+	if arg == "" {
+		arg = "2006-01-02"
+	}
+
+	if date := ctx.photo().DateTimeOriginal; !date.IsZero() {
+		return date.Format(arg), nil
+	}
+	return ctx.File.Info.ModTime().Format(arg), nil
+}
+
+// {camera}, the make and model of the camera that took the photo
+func cameraToken(ctx *templateContext, arg string) (string, error) {
+	return tagValue(ctx.photo().Camera(), "Unknown"), nil
+}
+
+// The EXIF data of the file, read once. Files without it, like videos or directories, get an empty one.
+func (ctx *templateContext) photo() *metadata.Exif {
+	if ctx.exif == nil {
+		exif, _ := metadata.ReadExif(ctx.File.Path)
+		ctx.exif = &exif
+	}
+	return ctx.exif
+}
+
+// {artist}, the artist of a song (or the album artist if it has none)
+func artistToken(ctx *templateContext, arg string) (string, error) {
+	return tagValue(ctx.song().Artist, "Unknown Artist"), nil
+}
+
+// {album}, the album of a song
+func albumToken(ctx *templateContext, arg string) (string, error) {
+	return tagValue(ctx.song().Album, "Unknown Album"), nil
+}
+
+// {title}, the title of a song, or the original name if it has none
+func titleToken(ctx *templateContext, arg string) (string, error) {
+	return tagValue(ctx.song().Title, common.GetPureFilename(ctx.File.Filename)), nil
+}
+
+// {track} or {track:02}, the track number of a song, zero padded to the given width
+func trackToken(ctx *templateContext, arg string) (string, error) {
+	width := 0
+	if arg != "" {
+		var err error
+		if width, err = strconv.Atoi(arg); err != nil || width < 0 {
+			return "", fmt.Errorf("'{track:%s}' must be a number of digits, like '{track:02}'", arg)
+		}
+	}
+	return padLeft(strconv.Itoa(ctx.song().Track), width, '0'), nil
+}
+
+// The audio tags of the file, read once. Files without them get empty tags.
+func (ctx *templateContext) song() *metadata.AudioTags {
+	if ctx.tags == nil {
+		tags, _ := metadata.ReadAudioTags(ctx.File.Path)
+		ctx.tags = &tags
+	}
+	return ctx.tags
+}
+
+// Tags and EXIF fields are free text, so characters that can't be in a name, like the slash of "AC/DC", become '_'
+func tagValue(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return strings.Map(func(char rune) rune {
+		if !checkForbiddenRunes(string(char)) {
+			return '_'
+		}
+		return char
+	}, value)
+}
+
+// Hex encoded SHA-256 of the contents of a file
+func fileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Pads a value on the left up to the given width
+func padLeft(value string, width int, pad rune) string {
+	if missing := width - len([]rune(value)); missing > 0 {
+		return strings.Repeat(string(pad), missing) + value
+	}
+	return value
+}
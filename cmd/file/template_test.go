@@ -0,0 +1,57 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"shelf/common"
+	"testing"
+)
+
+func TestRenderNameAndExtension(t *testing.T) {
+	root := t.TempDir()
+	stat := func(name string, dir bool) common.FileStats {
+		path := filepath.Join(root, name)
+		var err error
+		if dir {
+			err = os.Mkdir(path, 0o755)
+		} else {
+			err = os.WriteFile(path, make([]byte, 1536), 0o644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return common.FileStats{Info: info, Path: path, Filename: name}
+	}
+
+	tests := []struct {
+		name     string
+		file     common.FileStats
+		template string
+		want     string
+	}{
+		{"file", stat("report.final.pdf", false), "{name}_{ext}", "report.final_pdf"},
+		{"folder with a dot", stat("photos.2023", true), "{name}_{ext}", "photos.2023_"},
+		{"folder like an archive", stat("backup.tar.gz", true), "[{name}]{ext}", "[backup.tar.gz]"},
+		{"human size", stat("data.bin", false), "{name}-{size:h}", "data-1.5KB"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			template, err := parseTemplate(test.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, err := template.render(&templateContext{File: test.file})
+			if err != nil {
+				t.Fatalf("render() error = %v", err)
+			}
+			if name != test.want {
+				t.Errorf("render() = %q, want %q", name, test.want)
+			}
+		})
+	}
+}
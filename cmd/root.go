@@ -36,7 +36,10 @@ func init() {
 	// Finished Commands
 	rootCmd.AddCommand(singles.WhoamiCmd)
 	rootCmd.AddCommand(file.RenameCmd)
+	rootCmd.AddCommand(file.CheckNamesCmd)
+	rootCmd.AddCommand(file.CollisionsCmd)
 	rootCmd.AddCommand(duplicate.DuplicateCmd)
+	rootCmd.AddCommand(duplicate.CacheCmd)
 	rootCmd.AddCommand(diff.DiffCmd)
 
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
@@ -3,10 +3,12 @@ package common
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"shelf/config"
 	"strings"
 )
@@ -119,6 +121,39 @@ func ReadFilesRecursive(path string) (files []FileStats) {
 	return files
 }
 
+// Reads the entries of a directory, optionally descending into subdirectories and listing the directories themselves
+func ReadEntries(path string, recursive bool, includeDirs bool) (files []FileStats) {
+	err := filepath.Walk(path,
+		func(current string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if current == path {
+				return nil
+			}
+
+			if info.IsDir() {
+				if includeDirs {
+					files = append(files, FileStats{Info: info, Path: current, Filename: info.Name()})
+				}
+				if !recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			files = append(files, FileStats{Info: info, Path: current, Filename: info.Name()})
+			return nil
+		})
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return files
+}
+
 func GetCwd() string {
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -137,12 +172,69 @@ func GetExePath() string {
 	return strings.ReplaceAll(cwd, config.AppConfig.AppName+".exe", "")
 }
 
+// Directory where shelf keeps its own data, like the rename history. It's created if missing.
+func GetDataDir() string {
+	var base string
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			log.Fatal("Couldn't find the user data directory.")
+		}
+		base = dir
+	} else if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		base = dir
+	} else {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			log.Fatal("Couldn't find the user data directory.")
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+
+	dataDir := filepath.Join(base, config.AppConfig.AppName)
+	if err := os.MkdirAll(dataDir, os.ModePerm); err != nil {
+		log.Fatal("Couldn't create the user data directory.")
+	}
+	return dataDir
+}
+
+// Directory where shelf keeps data it can rebuild, like the file hashes. It's created if missing.
+func GetCacheDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		log.Fatal("Couldn't find the user cache directory.")
+	}
+
+	cacheDir := filepath.Join(base, config.AppConfig.AppName)
+	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
+		log.Fatal("Couldn't create the user cache directory.")
+	}
+	return cacheDir
+}
+
+// Extension of the filename with its leading dot, empty if there's none
 func GetFileExtension(filename string) string {
-	return "." + strings.Split(filename, ".")[1]
+	return ParseFilename(filename).Extension
 }
 
+// Filename without its extension
 func GetPureFilename(filename string) string {
-	return strings.Split(filename, ".")[0]
+	return ParseFilename(filename).Stem
+}
+
+// A size in the biggest unit it has, like 1.5 MB or 300 B
+func FormatBytes(bytes int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	size, unit := float64(bytes), 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", size), ".0") + " " + units[unit]
 }
 
 func ToBase64(b []byte) string {
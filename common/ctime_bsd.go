@@ -0,0 +1,17 @@
+//go:build darwin || freebsd || netbsd
+
+package common
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// The birth time of a file
+func CreationTime(info os.FileInfo) time.Time {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(stat.Birthtimespec.Unix())
+	}
+	return info.ModTime()
+}
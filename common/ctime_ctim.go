@@ -0,0 +1,17 @@
+//go:build linux || openbsd || dragonfly || solaris
+
+package common
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// The last status change of a file (ctime), these systems don't keep a creation time
+func CreationTime(info os.FileInfo) time.Time {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(stat.Ctim.Unix())
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !openbsd && !dragonfly && !solaris && !darwin && !freebsd && !netbsd && !windows
+
+package common
+
+import (
+	"os"
+	"time"
+)
+
+// Systems without a known creation or change time use the modification time
+func CreationTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package common
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// The creation time of a file
+func CreationTime(info os.FileInfo) time.Time {
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, data.CreationTime.Nanoseconds())
+	}
+	return info.ModTime()
+}
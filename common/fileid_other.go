@@ -0,0 +1,15 @@
+//go:build !unix
+
+package common
+
+import "os"
+
+// File systems without inodes in their stats don't give files an identity beyond their path
+func FileID(info os.FileInfo) (device uint64, inode uint64, ok bool) {
+	return 0, 0, false
+}
+
+// Owners aren't part of the stats on these systems
+func FileOwner(info os.FileInfo) (uid uint32, gid uint32, ok bool) {
+	return 0, 0, false
+}
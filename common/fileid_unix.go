@@ -0,0 +1,24 @@
+//go:build unix
+
+package common
+
+import (
+	"os"
+	"syscall"
+)
+
+// The device and inode of a file, which stay the same when it's renamed or moved within its file system
+func FileID(info os.FileInfo) (device uint64, inode uint64, ok bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Dev), uint64(stat.Ino), true
+	}
+	return 0, 0, false
+}
+
+// The user and group owning a file
+func FileOwner(info os.FileInfo) (uid uint32, gid uint32, ok bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint32(stat.Uid), uint32(stat.Gid), true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"shelf/config"
+	"strings"
+	"unicode"
+)
+
+// A filename split in its name and extension. Dotfiles like ".bashrc" have no extension, compound
+// extensions like ".tar.gz" stay together and files without a dot are all name.
+type Filename struct {
+	Stem      string
+	Extension string
+}
+
+// Splits a filename (not a path) in its name and extension, the extension keeps its leading dot
+func ParseFilename(name string) Filename {
+	// The leading dots of hidden files are part of the name, never an extension
+	hidden := len(name) - len(strings.TrimLeft(name, "."))
+	rest := name[hidden:]
+
+	lower := strings.ToLower(rest)
+	for _, compound := range config.AppConfig.CompoundExtensions {
+		if len(rest) > len(compound) && strings.HasSuffix(lower, strings.ToLower(compound)) {
+			split := len(name) - len(compound)
+			return Filename{Stem: name[:split], Extension: name[split:]}
+		}
+	}
+
+	dot := strings.LastIndexByte(rest, '.')
+	if dot <= 0 || dot == len(rest)-1 || strings.IndexFunc(rest[dot:], unicode.IsSpace) >= 0 {
+		return Filename{Stem: name}
+	}
+
+	split := hidden + dot
+	return Filename{Stem: name[:split], Extension: name[split:]}
+}
+
+// Joins the name and the extension back
+func (filename Filename) String() string {
+	return filename.Stem + filename.Extension
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package common
+
+import "os"
+
+// Systems without file locks run one shelf at a time
+func LockFile(file *os.File) error {
+	return nil
+}
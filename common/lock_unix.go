@@ -0,0 +1,15 @@
+//go:build unix
+
+package common
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// Waits for an exclusive lock on the whole file, released when it's closed
+func LockFile(file *os.File) error {
+	lock := unix.Flock_t{Type: unix.F_WRLCK, Whence: 0}
+	return unix.FcntlFlock(file.Fd(), unix.F_SETLKW, &lock)
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package common
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Waits for an exclusive lock on the whole file, released when it's closed
+func LockFile(file *os.File) error {
+	overlapped := windows.Overlapped{}
+	return windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, ^uint32(0), ^uint32(0), &overlapped)
+}
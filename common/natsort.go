@@ -1,86 +1,211 @@
-// Natural sort, by Facette.
+// Natural sort, based on the one by Facette.
 // Original Code: https://github.com/facette/natsort
 package common
 
 import (
-	"regexp"
 	"sort"
-	"strconv"
-)
+	"strings"
 
-type stringSlice []string
+	"golang.org/x/text/cases"
+)
 
-func (s stringSlice) Len() int {
-	return len(s)
+// How strings are compared by the natural order. The zero value compares the text byte by byte and the numbers
+// by value, so "file2" comes before "file10" and "v1.9" before "v1.10".
+type SortOptions struct {
+	// "readme" and "README" compare the same, ties are broken byte by byte
+	FoldCase bool
+	// Accented letters sort with their base letter, "é" next to "e" and not after "z"
+	Collate bool
+	// Numbers with the same value sort unpadded first, "1" before "01" before "001"
+	LeadingZeros bool
+	// Digits after a dot are a fraction, so "1.5" comes after "1.10" (0.5 > 0.1)
+	Decimals bool
+	// Pre-releases come before their release, "1.0-rc1" before "1.0" and "app-1.0~rc1.zip" before "app-1.0.zip"
+	Versions bool
 }
 
-func (s stringSlice) Less(a, b int) bool {
-	return Compare(s[a], s[b])
-}
+// The order of file browsers: case-insensitive, accent-insensitive and with padded numbers after unpadded ones
+var FileNameOrder = SortOptions{FoldCase: true, Collate: true, LeadingZeros: true}
 
-func (s stringSlice) Swap(a, b int) {
-	s[a], s[b] = s[b], s[a]
-}
+// Words that mark a pre-release when they follow a version
+var preReleases = []string{"alpha", "beta", "rc", "pre", "dev", "snapshot"}
 
-var chunkifyRegexp = regexp.MustCompile(`(\d+|\D+)`)
-
-func chunkify(s string) []string {
-	return chunkifyRegexp.FindAllString(s, -1)
+// A piece of a sort key, either text or a number split in its value digits and fraction
+type keyPart struct {
+	Text     string
+	Number   bool
+	Digits   string
+	Zeros    int
+	Fraction string
 }
 
-// Sort sorts a list of strings in a natural order
-func NaturalSort(l []string) {
-	sort.Sort(stringSlice(l))
+// A string split in parts once, so sorting doesn't split it again on every comparison
+type SortKey struct {
+	Raw   string
+	Parts []keyPart
+	opts  SortOptions
 }
 
-// Compare returns true if the first string precedes the second one according to natural order
-func Compare(a, b string) bool {
-	chunksA := chunkify(a)
-	chunksB := chunkify(b)
+// Splits a string in text and number parts following the options
+func NewSortKey(value string, options SortOptions) SortKey {
+	key := SortKey{Raw: value, opts: options}
+	text := value
+	if options.Collate {
+		text = Transliterate(text)
+	}
+	if options.FoldCase {
+		text = cases.Fold().String(text)
+	}
 
-	nChunksA := len(chunksA)
-	nChunksB := len(chunksB)
+	runes := []rune(text)
+	for index := 0; index < len(runes); {
+		start := index
+		if !isDigit(runes[index]) {
+			for index < len(runes) && !isDigit(runes[index]) {
+				index++
+			}
+			key.Parts = append(key.Parts, keyPart{Text: string(runes[start:index])})
+			continue
+		}
 
-	for i := range chunksA {
-		if i >= nChunksB {
-			return false
+		for index < len(runes) && isDigit(runes[index]) {
+			index++
 		}
+		digits := string(runes[start:index])
+		part := keyPart{Number: true, Digits: strings.TrimLeft(digits, "0")}
+		part.Zeros = len(digits) - len(part.Digits)
 
-		aInt, aErr := strconv.Atoi(chunksA[i])
-		bInt, bErr := strconv.Atoi(chunksB[i])
-
-		// If both chunks are numeric, compare them as integers
-		if aErr == nil && bErr == nil {
-			if aInt == bInt {
-				if i == nChunksA-1 {
-					// We reached the last chunk of A, thus B is greater than A
-					return true
-				} else if i == nChunksB-1 {
-					// We reached the last chunk of B, thus A is greater than B
-					return false
-				}
-
-				continue
+		if options.Decimals && index+1 < len(runes) && runes[index] == '.' && isDigit(runes[index+1]) {
+			fraction := index + 1
+			for index = fraction; index < len(runes) && isDigit(runes[index]); index++ {
 			}
+			part.Fraction = strings.TrimRight(string(runes[fraction:index]), "0")
+		}
+		key.Parts = append(key.Parts, part)
+	}
+	return key
+}
 
-			return aInt < bInt
+// Compares two keys made with the same options, returning -1, 0 or 1
+func (key SortKey) Compare(other SortKey) int {
+	zeros := 0
+	for index := 0; index < len(key.Parts) || index < len(other.Parts); index++ {
+		if rank, otherRank := key.opts.rank(key.Parts, index), key.opts.rank(other.Parts, index); rank != otherRank {
+			return compareInts(rank, otherRank)
 		}
 
-		// So far both strings are equal, continue to next chunk
-		if chunksA[i] == chunksB[i] {
-			if i == nChunksA-1 {
-				// We reached the last chunk of A, thus B is greater than A
-				return true
-			} else if i == nChunksB-1 {
-				// We reached the last chunk of B, thus A is greater than B
-				return false
+		a, b := key.Parts[index], other.Parts[index]
+		switch {
+		case a.Number && b.Number:
+			if order := compareNumbers(a, b); order != 0 {
+				return order
+			}
+			if zeros == 0 && a.Zeros != b.Zeros {
+				zeros = compareInts(a.Zeros, b.Zeros)
+			}
+		case a.Number != b.Number:
+			// Numbers come before text, like digits before letters
+			if a.Number {
+				return -1
+			}
+			return 1
+		default:
+			if order := strings.Compare(a.Text, b.Text); order != 0 {
+				return order
 			}
-
-			continue
 		}
+	}
+
+	if key.opts.LeadingZeros && zeros != 0 {
+		return zeros
+	}
+	return strings.Compare(key.Raw, other.Raw)
+}
+
+// Orders the part at an index against the other key's one before comparing their values: a pre-release comes before
+// the end of the key, which comes before anything else. Deciding it part by part keeps the order transitive, and puts
+// "1.0-rc" before "1.0", which is before "1.0-a", and "1.0~rc1.zip" before "1.0.zip".
+func (options SortOptions) rank(parts []keyPart, index int) int {
+	switch {
+	case index == len(parts):
+		return 1
+	case options.Versions && !parts[index].Number && isPreRelease(parts[index].Text):
+		return 0
+	}
+	return 2
+}
 
-		return chunksA[i] < chunksB[i]
+// Checks if a text part starts a pre-release, like "-rc", "~beta" or ".alpha"
+func isPreRelease(text string) bool {
+	if len(text) < 2 || !strings.ContainsRune("-~.+", rune(text[0])) {
+		return false
 	}
 
+	word := strings.ToLower(strings.TrimLeft(text[1:], "-_."))
+	for _, preRelease := range preReleases {
+		if strings.HasPrefix(word, preRelease) {
+			return true
+		}
+	}
 	return false
 }
+
+// Compares numbers of any length by value, without parsing them
+func compareNumbers(a keyPart, b keyPart) int {
+	if len(a.Digits) != len(b.Digits) {
+		return compareInts(len(a.Digits), len(b.Digits))
+	}
+	if order := strings.Compare(a.Digits, b.Digits); order != 0 {
+		return order
+	}
+	return strings.Compare(a.Fraction, b.Fraction)
+}
+
+func compareInts(a int, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Only ASCII digits, other scripts' digits are text
+func isDigit(char rune) bool {
+	return char >= '0' && char <= '9'
+}
+
+// Sorts the items by a string in natural order, splitting each string only once
+func SortBy[T any](items []T, value func(T) string, options SortOptions) {
+	keys := make([]SortKey, len(items))
+	for index, item := range items {
+		keys[index] = NewSortKey(value(item), options)
+	}
+
+	sort.Stable(&keyedSlice[T]{items: items, keys: keys})
+}
+
+// Sorts strings in natural order with the given options
+func SortStrings(list []string, options SortOptions) {
+	SortBy(list, func(value string) string { return value }, options)
+}
+
+// Sorts items and their keys together
+type keyedSlice[T any] struct {
+	items []T
+	keys  []SortKey
+}
+
+func (s *keyedSlice[T]) Len() int {
+	return len(s.items)
+}
+
+func (s *keyedSlice[T]) Less(a, b int) bool {
+	return s.keys[a].Compare(s.keys[b]) < 0
+}
+
+func (s *keyedSlice[T]) Swap(a, b int) {
+	s.items[a], s.items[b] = s.items[b], s.items[a]
+	s.keys[a], s.keys[b] = s.keys[b], s.keys[a]
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"slices"
+	"testing"
+)
+
+var sortCorpus = []string{
+	"", "1", "01", "001", "2", "10", "a", "A", "b", "é", "e", "z",
+	"file", "file1", "file01", "file2", "file10", "File2", "file.txt", "file1.txt", "file10.txt",
+	"1.0", "1.0-a", "1.0-rc", "1.0-rc1", "1.0-rc2", "1.0-beta", "1.0~alpha", "1.0.1", "1.0a", "1.1", "1.10", "1.5",
+	"app-1.0.zip", "app-1.0~rc1.zip", "app-1.0-rc.zip", "app-1.0.1.zip", "app-1.0-a.zip", "app-1.0",
+	"v1.9", "v1.10", "v1.9-rc", "x-rc", "x", "x.rc", "-rc", "+dev", "1.2.3+dev", "1.2.3",
+}
+
+var sortOptionSets = map[string]SortOptions{
+	"zero":       {},
+	"file names": FileNameOrder,
+	"decimals":   {FoldCase: true, Decimals: true},
+	"versions":   {FoldCase: true, Collate: true, Versions: true},
+	"everything": {FoldCase: true, Collate: true, LeadingZeros: true, Decimals: true, Versions: true},
+}
+
+func TestSortKeyCompareIsAnOrder(t *testing.T) {
+	for name, options := range sortOptionSets {
+		t.Run(name, func(t *testing.T) {
+			keys := make([]SortKey, len(sortCorpus))
+			for index, value := range sortCorpus {
+				keys[index] = NewSortKey(value, options)
+			}
+
+			for _, a := range keys {
+				if order := a.Compare(a); order != 0 {
+					t.Errorf("%q compares %d with itself", a.Raw, order)
+				}
+				for _, b := range keys {
+					if a.Compare(b) != -b.Compare(a) {
+						t.Errorf("%q and %q are not antisymmetric: %d and %d", a.Raw, b.Raw, a.Compare(b), b.Compare(a))
+					}
+					for _, c := range keys {
+						if a.Compare(b) < 0 && b.Compare(c) < 0 && a.Compare(c) >= 0 {
+							t.Errorf("%q < %q < %q but %q >= %q", a.Raw, b.Raw, c.Raw, a.Raw, c.Raw)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSortStrings(t *testing.T) {
+	tests := []struct {
+		name    string
+		options SortOptions
+		want    []string
+	}{
+		{"numbers by value", SortOptions{}, []string{"file1", "file2", "file10"}},
+		{"unpadded first", FileNameOrder, []string{"file1", "file01", "file001", "file2"}},
+		{"case folded", FileNameOrder, []string{"apple", "Banana", "cherry"}},
+		{"accents collated", FileNameOrder, []string{"e", "é", "f"}},
+		{"versions", SortOptions{}, []string{"v1.9", "v1.10"}},
+		{"decimals", SortOptions{Decimals: true}, []string{"1.10", "1.5"}},
+		{"pre-releases", SortOptions{Versions: true}, []string{"1.0-alpha", "1.0-beta", "1.0-rc1", "1.0-rc2", "1.0", "1.0-a", "1.0.1"}},
+		{"pre-releases before extensions", SortOptions{Versions: true}, []string{"app-1.0~rc1.zip", "app-1.0~rc2.zip", "app-1.0.zip"}},
+		{"pre-releases without versions", SortOptions{}, []string{"1.0", "1.0-rc1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, shuffled := range [][]string{slices.Clone(test.want), reversed(test.want)} {
+				SortStrings(shuffled, test.options)
+				if !slices.Equal(shuffled, test.want) {
+					t.Errorf("SortStrings() = %q, want %q", shuffled, test.want)
+				}
+			}
+		})
+	}
+}
+
+func reversed(list []string) []string {
+	clone := slices.Clone(list)
+	slices.Reverse(clone)
+	return clone
+}
@@ -0,0 +1,212 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Rules a filename must follow to be valid (and stay the same) on a platform
+type NameProfile struct {
+	Name           string
+	ForbiddenRunes []rune
+	// Forbids the ASCII control characters, NUL is always forbidden
+	ControlChars bool
+	// Forbids the device names of Windows, like CON, NUL or COM1, even with an extension
+	ReservedNames bool
+	// Forbids names ending in a dot or a space, which Windows silently strips
+	TrailingDotSpace bool
+	// Maximum length of a single name and of a whole path, in bytes or UTF-16 units, 0 for no limit
+	MaxComponent int
+	MaxPath      int
+	UTF16Length  bool
+	// Normal form the file system stores names in ("NFC", "NFD" or "" if it keeps them as they are)
+	Normalization string
+}
+
+var (
+	windowsForbidden = []rune{'<', '>', ':', '"', '/', '\\', '|', '?', '*'}
+	reservedNames    = map[string]bool{"CON": true, "PRN": true, "AUX": true, "NUL": true}
+
+	NameProfiles = map[string]NameProfile{
+		// Only the characters no common file system accepts, what rename always forbade
+		"basic": {
+			Name:           "basic",
+			ForbiddenRunes: windowsForbidden,
+		},
+		"posix": {
+			Name:           "posix",
+			ForbiddenRunes: []rune{'/'},
+			MaxComponent:   255,
+			MaxPath:        4096,
+		},
+		"windows": {
+			Name:             "windows",
+			ForbiddenRunes:   windowsForbidden,
+			ControlChars:     true,
+			ReservedNames:    true,
+			TrailingDotSpace: true,
+			MaxComponent:     255,
+			MaxPath:          260,
+			UTF16Length:      true,
+		},
+		"macos": {
+			Name:           "macos",
+			ForbiddenRunes: []rune{'/', ':'},
+			MaxComponent:   255,
+			MaxPath:        1024,
+			UTF16Length:    true,
+			Normalization:  "NFD",
+		},
+		"portable": {
+			Name:             "portable",
+			ForbiddenRunes:   windowsForbidden,
+			ControlChars:     true,
+			ReservedNames:    true,
+			TrailingDotSpace: true,
+			MaxComponent:     255,
+			MaxPath:          260,
+			Normalization:    "NFC",
+		},
+	}
+)
+
+func init() {
+	for n := 0; n <= 9; n++ {
+		reservedNames[fmt.Sprintf("COM%d", n)] = true
+		reservedNames[fmt.Sprintf("LPT%d", n)] = true
+	}
+}
+
+// Names of the available profiles, sorted
+func NameProfileNames() []string {
+	names := make([]string, 0, len(NameProfiles))
+	for name := range NameProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lists the rules a single filename (not a path) breaks, empty if it's valid
+func (profile NameProfile) Check(name string) (violations []string) {
+	if name == "" || name == "." || name == ".." {
+		return []string{"is not a valid name"}
+	}
+
+	if forbidden := profile.ForbiddenIn(name); len(forbidden) > 0 {
+		violations = append(violations, fmt.Sprintf("contains forbidden characters: %s", strings.Join(forbidden, " ")))
+	}
+	if profile.ReservedNames && profile.isReserved(name) {
+		violations = append(violations, "is a reserved device name on Windows")
+	}
+	if profile.TrailingDotSpace && strings.TrimRight(name, ". ") != name {
+		violations = append(violations, "ends with a dot or a space")
+	}
+	if length := profile.length(name); profile.MaxComponent > 0 && length > profile.MaxComponent {
+		violations = append(violations, fmt.Sprintf("is %d long, the maximum is %d", length, profile.MaxComponent))
+	}
+	if profile.Normalization == "NFC" && !norm.NFC.IsNormalString(name) {
+		violations = append(violations, "is not in Unicode NFC form, it may not match the same name typed on other systems")
+	} else if profile.Normalization == "NFD" && !norm.NFD.IsNormalString(name) {
+		violations = append(violations, "is not in Unicode NFD form, it will be stored decomposed")
+	}
+	return violations
+}
+
+// Checks the length of a whole path, which should be absolute since the limits count from the root or the drive
+func (profile NameProfile) CheckPath(path string) []string {
+	if length := profile.length(path); profile.MaxPath > 0 && length > profile.MaxPath {
+		return []string{fmt.Sprintf("has a path %d long, the maximum is %d", length, profile.MaxPath)}
+	}
+	return nil
+}
+
+// Changes a filename so it follows the profile, keeping its extension when it has to be shortened
+func (profile NameProfile) Fix(name string) string {
+	switch profile.Normalization {
+	case "NFC":
+		name = norm.NFC.String(name)
+	case "NFD":
+		name = norm.NFD.String(name)
+	}
+
+	name = strings.Map(func(char rune) rune {
+		if profile.isForbidden(char) {
+			return '_'
+		}
+		return char
+	}, name)
+
+	if profile.TrailingDotSpace {
+		name = strings.TrimRight(name, ". ")
+	}
+	if name == "" || name == "." || name == ".." {
+		name = "_"
+	}
+
+	if profile.ReservedNames && profile.isReserved(name) {
+		filename := ParseFilename(name)
+		name = filename.Stem + "_" + filename.Extension
+	}
+
+	if profile.MaxComponent > 0 && profile.length(name) > profile.MaxComponent {
+		filename := ParseFilename(name)
+		stem := []rune(filename.Stem)
+		for len(stem) > 1 && profile.length(string(stem)+filename.Extension) > profile.MaxComponent {
+			stem = stem[:len(stem)-1]
+		}
+		name = string(stem) + filename.Extension
+	}
+	return name
+}
+
+// Lists the forbidden characters found in a name, control characters are shown as their code
+func (profile NameProfile) ForbiddenIn(name string) (found []string) {
+	seen := make(map[rune]bool)
+	for _, char := range name {
+		if seen[char] || !profile.isForbidden(char) {
+			continue
+		}
+
+		seen[char] = true
+		if unicode.IsControl(char) {
+			found = append(found, fmt.Sprintf("U+%04X", char))
+		} else {
+			found = append(found, string(char))
+		}
+	}
+	return found
+}
+
+// Checks if the character can't be used in a name
+func (profile NameProfile) isForbidden(char rune) bool {
+	if char == 0 || (profile.ControlChars && char < 32) {
+		return true
+	}
+
+	for _, forbidden := range profile.ForbiddenRunes {
+		if char == forbidden {
+			return true
+		}
+	}
+	return false
+}
+
+// Windows reserves device names whatever the extension, "nul.tar.gz" included
+func (profile NameProfile) isReserved(name string) bool {
+	base, _, _ := strings.Cut(name, ".")
+	return reservedNames[strings.ToUpper(strings.TrimRight(base, " "))]
+}
+
+// Length of a name in the units of the file system
+func (profile NameProfile) length(name string) int {
+	if profile.UTF16Length {
+		return len(utf16.Encode([]rune(name)))
+	}
+	return len(name)
+}
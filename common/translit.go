@@ -0,0 +1,70 @@
+package common
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Letters that don't decompose into a base letter plus accents
+var transliterations = map[rune]string{
+	'ß': "ss", 'ẞ': "SS", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O", 'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D",
+	'ł': "l", 'Ł': "L", 'þ': "th", 'Þ': "TH", 'ħ': "h", 'Ħ': "H",
+	'ı': "i", 'ŋ': "ng", 'Ŋ': "NG", 'ſ': "s", 'ĸ': "k",
+}
+
+// Lower case Cyrillic (Russian, Ukrainian, Belarusian and Serbian) and Greek letters, the upper case ones are derived
+var (
+	cyrillic = map[rune]string{
+		'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i",
+		'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+		'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+		'э': "e", 'ю': "yu", 'я': "ya", 'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g", 'ў': "u", 'ђ': "dj", 'ј': "j",
+		'љ': "lj", 'њ': "nj", 'ћ': "c", 'џ': "dz",
+	}
+	greek = map[rune]string{
+		'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i", 'κ': "k",
+		'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t",
+		'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	}
+)
+
+func init() {
+	for _, alphabet := range []map[rune]string{cyrillic, greek} {
+		for letter, latin := range alphabet {
+			transliterations[letter] = latin
+			if latin != "" {
+				latin = strings.ToUpper(latin[:1]) + latin[1:]
+			}
+			transliterations[unicode.ToUpper(letter)] = latin
+		}
+	}
+}
+
+// Replaces accented, special latin, Cyrillic and Greek letters by their closest ASCII letters, like "Ação" to "Acao"
+// or "Москва" to "Moskva"
+func Transliterate(text string) string {
+	transliterated := strings.Builder{}
+	for _, char := range norm.NFC.String(text) {
+		// Letters like "й" are looked up before being decomposed, their marks change the sound
+		if replacement, ok := transliterations[char]; ok {
+			transliterated.WriteString(replacement)
+			continue
+		}
+
+		for _, part := range norm.NFKD.String(string(char)) {
+			if unicode.Is(unicode.Mn, part) {
+				continue
+			}
+
+			if replacement, ok := transliterations[part]; ok {
+				transliterated.WriteString(replacement)
+			} else {
+				transliterated.WriteRune(part)
+			}
+		}
+	}
+	return norm.NFC.String(transliterated.String())
+}
@@ -2,8 +2,17 @@ package config
 
 type Configuration struct {
 	AppName string
+	// Extensions made of more than one part, kept together when splitting a filename
+	CompoundExtensions []string
+	// Spellings of an extension replaced by its usual one when fixing extensions
+	ExtensionAliases map[string]string
 }
 
 var AppConfig Configuration = Configuration{
-	AppName: "shelf",
+	AppName:            "shelf",
+	CompoundExtensions: []string{".tar.gz", ".tar.bz2", ".tar.xz", ".tar.zst", ".tar.lz", ".tar.lzma", ".tar.z"},
+	ExtensionAliases: map[string]string{
+		".jpeg": ".jpg", ".jpe": ".jpg", ".jfif": ".jpg", ".tif": ".tiff", ".htm": ".html",
+		".mpeg": ".mpg", ".mpe": ".mpg", ".aif": ".aiff", ".midi": ".mid", ".wave": ".wav",
+	},
 }
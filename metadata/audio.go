@@ -0,0 +1,403 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// The tags of a song used to name it
+type AudioTags struct {
+	Artist string
+	Album  string
+	Title  string
+	Track  int
+}
+
+var ErrNoTags = errors.New("the file has no audio tags")
+
+// ID3v2 frames by version, in the order title, artist, album artist, album, track
+var id3Frames = map[byte][5]string{
+	2: {"TT2", "TP1", "TP2", "TAL", "TRK"},
+	3: {"TIT2", "TPE1", "TPE2", "TALB", "TRCK"},
+	4: {"TIT2", "TPE1", "TPE2", "TALB", "TRCK"},
+}
+
+// Reads the tags of an MP3 (ID3v2 and ID3v1), FLAC (Vorbis comments) or MP4/M4A (iTunes atoms) file
+func ReadAudioTags(path string) (AudioTags, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return AudioTags{}, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return AudioTags{}, ErrNoTags
+	}
+
+	var tags AudioTags
+	switch {
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		tags, err = readFLACTags(file)
+	case string(header[4:8]) == "ftyp":
+		tags, err = readMP4Tags(file)
+	default:
+		if bytes.HasPrefix(header, []byte("ID3")) {
+			tags, err = readID3v2(file, header)
+		}
+
+		// ID3v1 only fills what the ID3v2 tag left empty, its fields are cut at 30 characters
+		if v1, v1Err := readID3v1(file); v1Err == nil {
+			tags = tags.merge(v1)
+			err = nil
+		}
+	}
+
+	if err == nil && tags == (AudioTags{}) {
+		err = ErrNoTags
+	}
+	return tags, err
+}
+
+// Fills the empty fields with the ones of other tags
+func (tags AudioTags) merge(other AudioTags) AudioTags {
+	if tags.Artist == "" {
+		tags.Artist = other.Artist
+	}
+	if tags.Album == "" {
+		tags.Album = other.Album
+	}
+	if tags.Title == "" {
+		tags.Title = other.Title
+	}
+	if tags.Track == 0 {
+		tags.Track = other.Track
+	}
+	return tags
+}
+
+// Reads the ID3v2.2, v2.3 or v2.4 tag at the start of the file
+func readID3v2(file io.Reader, header []byte) (AudioTags, error) {
+	version, flags := header[3], header[5]
+	frameIDs, ok := id3Frames[version]
+	if !ok {
+		return AudioTags{}, ErrNoTags
+	}
+
+	tag := make([]byte, syncsafe(header[6:10]))
+	if _, err := io.ReadFull(file, tag); err != nil {
+		return AudioTags{}, ErrNoTags
+	}
+
+	// Unsynchronisation of the whole tag, v2.4 marks it per frame instead
+	if flags&0x80 != 0 && version < 4 {
+		tag = bytes.ReplaceAll(tag, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+
+	// The extended header is skipped, its size counts itself in v2.4 but not in v2.3
+	if flags&0x40 != 0 && version >= 3 && len(tag) >= 4 {
+		size := int(binary.BigEndian.Uint32(tag)) + 4
+		if version == 4 {
+			size = syncsafe(tag[:4])
+		}
+		tag = tag[min(size, len(tag)):]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	frames := make(map[string]string)
+	for len(tag) >= headerSize && tag[0] != 0 {
+		id := string(tag[:idSize])
+
+		var size int
+		var frameFlags byte
+		switch version {
+		case 2:
+			size = int(tag[3])<<16 | int(tag[4])<<8 | int(tag[5])
+		case 3:
+			size, frameFlags = int(binary.BigEndian.Uint32(tag[4:])), tag[9]
+		case 4:
+			size, frameFlags = syncsafe(tag[4:8]), tag[9]
+		}
+		if size <= 0 || headerSize+size > len(tag) {
+			break
+		}
+
+		data := tag[headerSize : headerSize+size]
+		tag = tag[headerSize+size:]
+
+		// Compressed and encrypted frames are skipped, text frames are never stored like that in practice
+		if version == 3 && frameFlags&0xC0 != 0 || version == 4 && frameFlags&0x0C != 0 {
+			continue
+		}
+		if version == 4 && frameFlags&0x01 != 0 && len(data) >= 4 {
+			data = data[4:]
+		}
+		if version == 4 && frameFlags&0x02 != 0 {
+			data = bytes.ReplaceAll(data, []byte{0xFF, 0x00}, []byte{0xFF})
+		}
+
+		if strings.HasPrefix(id, "T") && frames[id] == "" {
+			frames[id] = decodeID3Text(data)
+		}
+	}
+
+	tags := AudioTags{
+		Title:  frames[frameIDs[0]],
+		Artist: frames[frameIDs[1]],
+		Album:  frames[frameIDs[3]],
+		Track:  trackNumber(frames[frameIDs[4]]),
+	}
+	if tags.Artist == "" {
+		tags.Artist = frames[frameIDs[2]]
+	}
+	return tags, nil
+}
+
+// Decodes the 7 bits per byte sizes of ID3v2
+func syncsafe(size []byte) int {
+	value := 0
+	for _, b := range size {
+		value = value<<7 | int(b&0x7F)
+	}
+	return value
+}
+
+// Decodes an ID3v2 text frame, only the first of multiple values is kept
+func decodeID3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	encoding, text := data[0], data[1:]
+	var decoded string
+	switch encoding {
+	case 1, 2:
+		decoded = decodeUTF16(text, encoding == 2)
+	case 3:
+		decoded = string(text)
+	default:
+		decoded = decodeLatin1(text)
+	}
+
+	if end := strings.IndexByte(decoded, 0); end >= 0 {
+		decoded = decoded[:end]
+	}
+	return strings.TrimSpace(decoded)
+}
+
+// Decodes UTF-16 text, following its byte order mark unless it's known to be big endian
+func decodeUTF16(text []byte, bigEndian bool) string {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	if len(text) >= 2 && text[0] == 0xFE && text[1] == 0xFF {
+		order, text = binary.BigEndian, text[2:]
+	} else if len(text) >= 2 && text[0] == 0xFF && text[1] == 0xFE {
+		order, text = binary.LittleEndian, text[2:]
+	}
+
+	units := make([]uint16, 0, len(text)/2)
+	for index := 0; index+1 < len(text); index += 2 {
+		unit := order.Uint16(text[index:])
+		if unit == 0 {
+			break
+		}
+		units = append(units, unit)
+	}
+	return string(utf16.Decode(units))
+}
+
+// ISO-8859-1 maps one to one to the first Unicode code points
+func decodeLatin1(text []byte) string {
+	runes := make([]rune, len(text))
+	for index, b := range text {
+		runes[index] = rune(b)
+	}
+	return string(runes)
+}
+
+// Reads the 128 bytes ID3v1 tag at the end of the file, with the ID3v1.1 track number
+func readID3v1(file io.ReadSeeker) (AudioTags, error) {
+	tag := make([]byte, 128)
+	if _, err := file.Seek(-128, io.SeekEnd); err != nil {
+		return AudioTags{}, ErrNoTags
+	}
+	if _, err := io.ReadFull(file, tag); err != nil || !bytes.HasPrefix(tag, []byte("TAG")) {
+		return AudioTags{}, ErrNoTags
+	}
+
+	field := func(from int, to int) string {
+		return strings.TrimSpace(strings.TrimRight(decodeLatin1(tag[from:to]), "\x00"))
+	}
+
+	tags := AudioTags{Title: field(3, 33), Artist: field(33, 63), Album: field(63, 93)}
+	if tag[125] == 0 {
+		tags.Track = int(tag[126])
+	}
+	return tags, nil
+}
+
+// Reads the Vorbis comment block among the metadata blocks of a FLAC file
+func readFLACTags(file io.ReadSeeker) (AudioTags, error) {
+	if _, err := file.Seek(4, io.SeekStart); err != nil {
+		return AudioTags{}, err
+	}
+
+	const vorbisCommentBlock = 4
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(file, header); err != nil {
+			return AudioTags{}, ErrNoTags
+		}
+
+		last, kind := header[0]&0x80 != 0, header[0]&0x7F
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		if kind == vorbisCommentBlock {
+			block := make([]byte, size)
+			if _, err := io.ReadFull(file, block); err != nil {
+				return AudioTags{}, ErrNoTags
+			}
+			return parseVorbisComments(block), nil
+		}
+
+		if last {
+			return AudioTags{}, ErrNoTags
+		}
+		if _, err := file.Seek(int64(size), io.SeekCurrent); err != nil {
+			return AudioTags{}, err
+		}
+	}
+}
+
+// Parses the little endian "KEY=value" list of a Vorbis comment, keys are case-insensitive
+func parseVorbisComments(block []byte) AudioTags {
+	next := func() ([]byte, bool) {
+		if len(block) < 4 {
+			return nil, false
+		}
+		size := int(binary.LittleEndian.Uint32(block))
+		if size < 0 || 4+size > len(block) {
+			return nil, false
+		}
+		value := block[4 : 4+size]
+		block = block[4+size:]
+		return value, true
+	}
+
+	if _, ok := next(); !ok {
+		return AudioTags{}
+	}
+	if len(block) < 4 {
+		return AudioTags{}
+	}
+	count := int(binary.LittleEndian.Uint32(block))
+	block = block[4:]
+
+	comments := make(map[string]string)
+	for index := 0; index < count; index++ {
+		comment, ok := next()
+		if !ok {
+			break
+		}
+
+		key, value, found := strings.Cut(string(comment), "=")
+		if key = strings.ToUpper(key); found && comments[key] == "" {
+			comments[key] = strings.TrimSpace(value)
+		}
+	}
+
+	tags := AudioTags{
+		Title:  comments["TITLE"],
+		Artist: comments["ARTIST"],
+		Album:  comments["ALBUM"],
+		Track:  trackNumber(comments["TRACKNUMBER"]),
+	}
+	if tags.Artist == "" {
+		tags.Artist = comments["ALBUMARTIST"]
+	}
+	return tags
+}
+
+// Reads the iTunes item list at moov/udta/meta/ilst of an MP4 file
+func readMP4Tags(file io.ReadSeeker) (AudioTags, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return AudioTags{}, err
+	}
+
+	moov, err := findBox(file, "moov", 64<<20)
+	if err != nil {
+		return AudioTags{}, ErrNoTags
+	}
+
+	meta := childBox(childBox(moov, "udta"), "meta")
+	// The meta box is a full box in MP4 files but a plain one in QuickTime files
+	if len(meta) >= 8 && string(meta[4:8]) != "hdlr" {
+		meta = meta[4:]
+	}
+
+	ilst := childBox(meta, "ilst")
+	if ilst == nil {
+		return AudioTags{}, ErrNoTags
+	}
+
+	tags := AudioTags{
+		Title:  string(itemData(ilst, "\xa9nam")),
+		Artist: string(itemData(ilst, "\xa9ART")),
+		Album:  string(itemData(ilst, "\xa9alb")),
+	}
+	if tags.Artist == "" {
+		tags.Artist = string(itemData(ilst, "aART"))
+	}
+
+	// The track is stored as reserved (2 bytes), number (2 bytes) and total (2 bytes)
+	if track := itemData(ilst, "trkn"); len(track) >= 4 {
+		tags.Track = int(binary.BigEndian.Uint16(track[2:]))
+	}
+	return tags, nil
+}
+
+// Finds the contents of a child box inside the contents of another box
+func childBox(boxes []byte, boxType string) []byte {
+	for len(boxes) >= 8 {
+		size := int(binary.BigEndian.Uint32(boxes))
+		if size < 8 || size > len(boxes) {
+			return nil
+		}
+
+		if string(boxes[4:8]) == boxType {
+			return boxes[8:size]
+		}
+		boxes = boxes[size:]
+	}
+	return nil
+}
+
+// Reads the value of an item of the list, stored in its "data" box after the type and locale
+func itemData(ilst []byte, item string) []byte {
+	data := childBox(childBox(ilst, item), "data")
+	if len(data) < 8 {
+		return nil
+	}
+	return data[8:]
+}
+
+// Parses track numbers like "3" or "3/12"
+func trackNumber(value string) int {
+	number, _, _ := strings.Cut(value, "/")
+	track, err := strconv.Atoi(strings.TrimSpace(number))
+	if err != nil || track < 0 {
+		return 0
+	}
+	return track
+}
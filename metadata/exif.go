@@ -0,0 +1,401 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// The EXIF fields of a photo used to name it
+type Exif struct {
+	DateTimeOriginal time.Time
+	Make             string
+	Model            string
+}
+
+// TIFF tags read from the photos
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagDateTime           = 0x0132
+	tagExifIFD            = 0x8769
+	tagDateTimeOriginal   = 0x9003
+	tagDateTimeDigitized  = 0x9004
+	tagOffsetTimeOriginal = 0x9011
+)
+
+var ErrNoExif = errors.New("the file has no EXIF data")
+
+// Reads the EXIF data of a JPEG, TIFF (raw formats included) or HEIC photo
+func ReadExif(path string) (Exif, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Exif{}, err
+	}
+	defer file.Close()
+	return readExif(file)
+}
+
+// Anything the EXIF data can be read from, like a file or the bytes of one
+type exifSource interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// Tells the container apart by its first bytes and reads its EXIF data
+func readExif(file exifSource) (Exif, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return Exif{}, ErrNoExif
+	}
+
+	switch {
+	case header[0] == 0xFF && header[1] == 0xD8:
+		return readJPEGExif(file)
+	case bytes.HasPrefix(header, []byte("II*\x00")) || bytes.HasPrefix(header, []byte("MM\x00*")):
+		return readTIFF(file, 0)
+	case string(header[4:8]) == "ftyp":
+		return readHEICExif(file)
+	}
+	return Exif{}, ErrNoExif
+}
+
+// Finds the APP1 "Exif" segment among the segments before the image data
+func readJPEGExif(file io.ReadSeeker) (Exif, error) {
+	if _, err := file.Seek(2, io.SeekStart); err != nil {
+		return Exif{}, err
+	}
+
+	marker := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(file, marker); err != nil || marker[0] != 0xFF {
+			return Exif{}, ErrNoExif
+		}
+
+		// Start of scan or end of image, the metadata always comes before them
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			return Exif{}, ErrNoExif
+		}
+
+		length := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if length < 0 {
+			return Exif{}, ErrNoExif
+		}
+
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(file, segment); err != nil {
+			return Exif{}, ErrNoExif
+		}
+		if marker[1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return readTIFF(bytes.NewReader(segment), 6)
+		}
+	}
+}
+
+// Finds the "Exif" item of a HEIF container through the item info (iinf) and item location (iloc) boxes of the meta box
+func readHEICExif(file io.ReadSeeker) (Exif, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Exif{}, err
+	}
+
+	meta, err := findBox(file, "meta", 1<<20)
+	if err != nil || len(meta) < 4 {
+		return Exif{}, ErrNoExif
+	}
+
+	// The meta box is a full box, its children start after the version and flags
+	itemID := exifItemID(childBox(meta[4:], "iinf"))
+	location, ok := itemLocations(childBox(meta[4:], "iloc"))[itemID]
+	if itemID == 0 || !ok || location[1] < 10 || location[1] > 1<<20 {
+		return Exif{}, ErrNoExif
+	}
+
+	data := make([]byte, location[1])
+	if _, err := file.Seek(int64(location[0]), io.SeekStart); err != nil {
+		return Exif{}, err
+	}
+	if _, err := io.ReadFull(file, data); err != nil {
+		return Exif{}, ErrNoExif
+	}
+
+	// The item starts with the offset to the TIFF header, which is usually preceded by "Exif\0\0"
+	offset := int64(binary.BigEndian.Uint32(data)) + 4
+	if offset >= int64(len(data)) {
+		return Exif{}, ErrNoExif
+	}
+	return readTIFF(bytes.NewReader(data), offset)
+}
+
+// Reads the contents of the first box of the given type at the current level, skipping over the others
+func findBox(file io.ReadSeeker, boxType string, maxSize uint64) ([]byte, error) {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(file, header); err != nil {
+			return nil, err
+		}
+
+		size, headerSize := uint64(binary.BigEndian.Uint32(header)), uint64(8)
+		if size == 1 {
+			large := make([]byte, 8)
+			if _, err := io.ReadFull(file, large); err != nil {
+				return nil, err
+			}
+			size, headerSize = binary.BigEndian.Uint64(large), 16
+		}
+		if size < headerSize {
+			return nil, ErrNoExif
+		}
+
+		if string(header[4:8]) == boxType {
+			if size-headerSize > maxSize {
+				return nil, ErrNoExif
+			}
+			content := make([]byte, size-headerSize)
+			_, err := io.ReadFull(file, content)
+			return content, err
+		}
+
+		// Media data boxes can be gigabytes long, they are skipped without being read
+		if _, err := file.Seek(int64(size-headerSize), io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Finds the id of the "Exif" item in an iinf box
+func exifItemID(iinf []byte) uint32 {
+	// Version 0 counts the entries in 2 bytes, later versions in 4
+	if len(iinf) < 6 || (iinf[0] != 0 && len(iinf) < 8) {
+		return 0
+	}
+
+	entries := iinf[6:]
+	if iinf[0] != 0 {
+		entries = iinf[8:]
+	}
+
+	for len(entries) >= 8 {
+		size := int(binary.BigEndian.Uint32(entries))
+		if size < 8 || size > len(entries) {
+			return 0
+		}
+
+		// Only the version 2 and 3 item entries carry an item type
+		infe := entries[8:size]
+		if string(entries[4:8]) == "infe" && len(infe) >= 4 && infe[0] >= 2 {
+			var id uint32
+			var itemType []byte
+			if infe[0] == 2 && len(infe) >= 12 {
+				id, itemType = uint32(binary.BigEndian.Uint16(infe[4:])), infe[8:12]
+			} else if infe[0] == 3 && len(infe) >= 14 {
+				id, itemType = binary.BigEndian.Uint32(infe[4:]), infe[10:14]
+			}
+			if string(itemType) == "Exif" {
+				return id
+			}
+		}
+		entries = entries[size:]
+	}
+	return 0
+}
+
+// Reads the offset and length of the first extent of each item in an iloc box
+func itemLocations(iloc []byte) map[uint32][2]uint64 {
+	locations := make(map[uint32][2]uint64)
+	if len(iloc) < 8 {
+		return locations
+	}
+
+	version := iloc[0]
+	offsetSize, lengthSize := int(iloc[4]>>4), int(iloc[4]&0x0F)
+	baseOffsetSize, indexSize := int(iloc[5]>>4), int(iloc[5]&0x0F)
+	if version == 0 {
+		indexSize = 0
+	}
+
+	reader := &fieldReader{data: iloc, position: 6}
+	count := reader.uint(2)
+	if version == 2 {
+		count = reader.uint(4)
+	}
+
+	for item := uint64(0); item < count && !reader.failed; item++ {
+		id := reader.uint(2)
+		if version == 2 {
+			id = reader.uint(4)
+		}
+
+		constructionMethod := uint64(0)
+		if version == 1 || version == 2 {
+			constructionMethod = reader.uint(2) & 0x0F
+		}
+
+		reader.uint(2) // data reference index
+		baseOffset := reader.uint(baseOffsetSize)
+		extents := reader.uint(2)
+		for extent := uint64(0); extent < extents && !reader.failed; extent++ {
+			reader.uint(indexSize)
+			offset, length := reader.uint(offsetSize), reader.uint(lengthSize)
+
+			// Items stored inside the idat box aren't supported, they are rare for EXIF data
+			if _, ok := locations[uint32(id)]; !ok && extent == 0 && constructionMethod == 0 {
+				locations[uint32(id)] = [2]uint64{baseOffset + offset, length}
+			}
+		}
+	}
+	return locations
+}
+
+// Reads big endian fields of variable size, remembering if the data ran out
+type fieldReader struct {
+	data     []byte
+	position int
+	failed   bool
+}
+
+func (reader *fieldReader) uint(size int) (value uint64) {
+	if reader.position+size > len(reader.data) {
+		reader.failed = true
+		return 0
+	}
+
+	for _, b := range reader.data[reader.position : reader.position+size] {
+		value = value<<8 | uint64(b)
+	}
+	reader.position += size
+	return value
+}
+
+// A TIFF structure found at the given offset of a file or segment, the IFD offsets are relative to it
+type tiffReader struct {
+	reader io.ReaderAt
+	base   int64
+	order  binary.ByteOrder
+}
+
+// Reads the camera from IFD0 and the capture date from the EXIF IFD
+func readTIFF(reader io.ReaderAt, base int64) (Exif, error) {
+	header := make([]byte, 8)
+	if _, err := reader.ReadAt(header, base); err != nil {
+		return Exif{}, ErrNoExif
+	}
+
+	tiff := tiffReader{reader: reader, base: base}
+	switch string(header[:2]) {
+	case "II":
+		tiff.order = binary.LittleEndian
+	case "MM":
+		tiff.order = binary.BigEndian
+	default:
+		return Exif{}, ErrNoExif
+	}
+	if tiff.order.Uint16(header[2:]) != 42 {
+		return Exif{}, ErrNoExif
+	}
+
+	ifd0, err := tiff.readIFD(tiff.order.Uint32(header[4:]))
+	if err != nil {
+		return Exif{}, err
+	}
+
+	exif := Exif{Make: tiff.text(ifd0[tagMake]), Model: tiff.text(ifd0[tagModel])}
+	date, offset := tiff.text(ifd0[tagDateTime]), ""
+	if pointer, ok := ifd0[tagExifIFD]; ok {
+		if exifIFD, err := tiff.readIFD(tiff.order.Uint32(pointer.value)); err == nil {
+			if original := tiff.text(exifIFD[tagDateTimeOriginal]); original != "" {
+				date, offset = original, tiff.text(exifIFD[tagOffsetTimeOriginal])
+			} else if digitized := tiff.text(exifIFD[tagDateTimeDigitized]); digitized != "" {
+				date = digitized
+			}
+		}
+	}
+
+	exif.DateTimeOriginal = parseExifDate(date, offset)
+	if exif.DateTimeOriginal.IsZero() && exif.Make == "" && exif.Model == "" {
+		return Exif{}, ErrNoExif
+	}
+	return exif, nil
+}
+
+// An IFD entry, the value holds the 4 bytes of the value or of the offset to it
+type tiffEntry struct {
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// Reads the entries of an IFD by tag
+func (tiff tiffReader) readIFD(offset uint32) (map[uint16]tiffEntry, error) {
+	countBytes := make([]byte, 2)
+	if _, err := tiff.reader.ReadAt(countBytes, tiff.base+int64(offset)); err != nil {
+		return nil, ErrNoExif
+	}
+
+	count := int(tiff.order.Uint16(countBytes))
+	raw := make([]byte, count*12)
+	if _, err := tiff.reader.ReadAt(raw, tiff.base+int64(offset)+2); err != nil {
+		return nil, ErrNoExif
+	}
+
+	entries := make(map[uint16]tiffEntry, count)
+	for index := 0; index < count; index++ {
+		field := raw[index*12 : index*12+12]
+		entries[tiff.order.Uint16(field)] = tiffEntry{
+			kind:  tiff.order.Uint16(field[2:]),
+			count: tiff.order.Uint32(field[4:]),
+			value: field[8:12],
+		}
+	}
+	return entries, nil
+}
+
+// Reads an ASCII entry, without the trailing NUL and padding
+func (tiff tiffReader) text(entry tiffEntry) string {
+	const asciiType = 2
+	if entry.kind != asciiType || entry.count == 0 || entry.count > 1024 {
+		return ""
+	}
+
+	data := entry.value[:min(int(entry.count), 4)]
+	if entry.count > 4 {
+		data = make([]byte, entry.count)
+		if _, err := tiff.reader.ReadAt(data, tiff.base+int64(tiff.order.Uint32(entry.value))); err != nil {
+			return ""
+		}
+	}
+
+	if end := bytes.IndexByte(data, 0); end >= 0 {
+		data = data[:end]
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Parses an EXIF "2006:01:02 15:04:05" date, in the given "+02:00" offset or the local time zone
+func parseExifDate(date string, offset string) time.Time {
+	location := time.Local
+	if zone, err := time.Parse("-07:00", offset); err == nil {
+		location = zone.Location()
+	}
+
+	parsed, err := time.ParseInLocation("2006:01:02 15:04:05", date, location)
+	if err != nil {
+		return time.Time{}
+	}
+	return parsed
+}
+
+// Name of the camera, with its make unless the model already starts with it ("Canon EOS R5", "Apple iPhone 12", "NIKON D750")
+func (exif Exif) Camera() string {
+	brand := strings.Fields(exif.Make)
+	if len(brand) == 0 || strings.HasPrefix(strings.ToLower(exif.Model), strings.ToLower(brand[0])) {
+		return exif.Model
+	}
+	if exif.Model == "" {
+		return exif.Make
+	}
+	return exif.Make + " " + exif.Model
+}
@@ -0,0 +1,154 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// A little endian TIFF with the make and model in IFD0 and the capture date in the EXIF IFD
+func makeTIFF(brand string, model string, date string) []byte {
+	type entry struct {
+		tag   uint16
+		value string
+	}
+	ifd := func(offset int, entries []entry, pointer uint32) []byte {
+		data := offset + 2 + len(entries)*12 + 4
+		table, values := binary.LittleEndian.AppendUint16(nil, uint16(len(entries))), []byte{}
+		for _, e := range entries {
+			table = binary.LittleEndian.AppendUint16(table, e.tag)
+			if e.tag == tagExifIFD {
+				table = binary.LittleEndian.AppendUint16(table, 4)
+				table = binary.LittleEndian.AppendUint32(table, 1)
+				table = binary.LittleEndian.AppendUint32(table, pointer)
+				continue
+			}
+			text := e.value + "\x00"
+			table = binary.LittleEndian.AppendUint16(table, 2)
+			table = binary.LittleEndian.AppendUint32(table, uint32(len(text)))
+			table = binary.LittleEndian.AppendUint32(table, uint32(data+len(values)))
+			values = append(values, text...)
+		}
+		return append(append(table, 0, 0, 0, 0), values...)
+	}
+
+	ifd0 := ifd(8, []entry{{tagMake, brand}, {tagModel, model}, {tagExifIFD, ""}}, 0)
+	exifOffset := 8 + len(ifd0)
+	ifd0 = ifd(8, []entry{{tagMake, brand}, {tagModel, model}, {tagExifIFD, ""}}, uint32(exifOffset))
+	exifIFD := ifd(exifOffset, []entry{{tagDateTimeOriginal, date}, {tagOffsetTimeOriginal, "+02:00"}}, 0)
+	return append(append([]byte("II*\x00\x08\x00\x00\x00"), ifd0...), exifIFD...)
+}
+
+func makeJPEG(tiff []byte) []byte {
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	jpeg = binary.BigEndian.AppendUint16(jpeg, uint16(len(segment)+2))
+	return append(append(jpeg, segment...), 0xFF, 0xD9)
+}
+
+// Concatenates copies, so cases built on the same prefix don't share its array
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func box(boxType string, contents ...[]byte) []byte {
+	content := bytes.Join(contents, nil)
+	return append(append(binary.BigEndian.AppendUint32(nil, uint32(len(content)+8)), boxType...), content...)
+}
+
+// A HEIF with one "Exif" item (id 1) stored after the meta box
+func makeHEIC(tiff []byte) []byte {
+	item := append([]byte{0, 0, 0, 6}, append([]byte("Exif\x00\x00"), tiff...)...)
+	ftyp := box("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+	infe := box("infe", []byte{2, 0, 0, 0, 0, 1, 0, 0}, []byte("Exif"), []byte{0})
+	iinf := box("iinf", []byte{0, 0, 0, 0, 0, 1}, infe)
+
+	ilocFor := func(offset uint32) []byte {
+		iloc := []byte{0, 0, 0, 0, 0x44, 0x00, 0, 1, 0, 1, 0, 0, 0, 1}
+		iloc = binary.BigEndian.AppendUint32(iloc, offset)
+		iloc = binary.BigEndian.AppendUint32(iloc, uint32(len(item)))
+		return box("iloc", iloc)
+	}
+	meta := box("meta", []byte{0, 0, 0, 0}, iinf, ilocFor(0))
+	offset := uint32(len(ftyp) + len(meta) + 8)
+	meta = box("meta", []byte{0, 0, 0, 0}, iinf, ilocFor(offset))
+	return join(ftyp, meta, box("mdat", item))
+}
+
+func TestReadExif(t *testing.T) {
+	tiff := makeTIFF("Canon", "Canon EOS R5", "2023:07:14 18:30:05")
+	want := time.Date(2023, 7, 14, 18, 30, 5, 0, time.FixedZone("", 2*60*60))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"jpeg", makeJPEG(tiff)},
+		{"tiff", tiff},
+		{"heic", makeHEIC(tiff)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exif, err := readExif(bytes.NewReader(test.data))
+			if err != nil {
+				t.Fatalf("readExif() error = %v", err)
+			}
+			if exif.Camera() != "Canon EOS R5" || !exif.DateTimeOriginal.Equal(want) {
+				t.Errorf("readExif() = %q at %v, want %q at %v", exif.Camera(), exif.DateTimeOriginal, "Canon EOS R5", want)
+			}
+		})
+	}
+}
+
+func TestReadExifMalformed(t *testing.T) {
+	ftyp := box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2mp41"))
+	heic := makeHEIC(makeTIFF("Apple", "iPhone 12", "2021:01:02 03:04:05"))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"ftyp only", ftyp},
+		{"truncated ftyp", ftyp[:12]},
+		{"truncated video", join(ftyp, box("mdat", make([]byte, 64))[:20])},
+		{"empty meta", join(ftyp, box("meta"))},
+		{"short iinf", join(ftyp, box("meta", []byte{0, 0, 0, 0}, box("iinf", []byte{1, 0, 0, 0, 0, 1})))},
+		{"iinf entry past its box", join(ftyp, box("meta", []byte{0, 0, 0, 0}, box("iinf", []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0x40})))},
+		{"truncated iloc", join(ftyp, box("meta", []byte{0, 0, 0, 0}, box("iloc", []byte{1, 0, 0, 0, 0x44, 0x40, 0, 9})))},
+		{"huge box size", join(ftyp, []byte("\xFF\xFF\xFF\xFFmeta"))},
+		{"large box size", join(ftyp, []byte("\x00\x00\x00\x01moov\xFF\xFF\xFF\xFF\xFF\xFF\xFF\xFF"))},
+		{"truncated heic", heic[:len(heic)-40]},
+		{"jpeg without exif", []byte{0xFF, 0xD8, 0xFF, 0xDB, 0x00, 0x04, 0, 0, 0xFF, 0xD9}},
+		{"jpeg with a short segment", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01, 0, 0, 0, 0, 0, 0}},
+		{"tiff with the IFD past the end", []byte("II*\x00\xFF\xFF\xFF\x00\x00\x00\x00\x00")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := readExif(bytes.NewReader(test.data)); err == nil {
+				t.Errorf("readExif() found EXIF data in a malformed file")
+			}
+		})
+	}
+}
+
+func TestReadExifMissingFile(t *testing.T) {
+	if _, err := ReadExif(t.TempDir() + "/missing.jpg"); err == nil || errors.Is(err, ErrNoExif) {
+		t.Errorf("ReadExif() error = %v, want the open error", err)
+	}
+}
+
+func FuzzReadExif(f *testing.F) {
+	tiff := makeTIFF("NIKON CORPORATION", "NIKON D750", "2019:12:31 23:59:59")
+	f.Add(makeJPEG(tiff))
+	f.Add(tiff)
+	f.Add(makeHEIC(tiff))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		readExif(bytes.NewReader(data))
+	})
+}
@@ -0,0 +1,290 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// A file format recognized by its content. Accepts lists other extensions that are right for the same content,
+// like "jar" for a zip file, and Extension is empty for formats that usually go without one, like ELF binaries.
+type FileType struct {
+	Kind      string
+	Extension string
+	Accepts   []string
+}
+
+// Checks if an extension (lower case, without the dot) is right for the format
+func (fileType FileType) Matches(extension string) bool {
+	if extension == fileType.Extension {
+		return true
+	}
+	for _, accepted := range fileType.Accepts {
+		if extension == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// A magic number, or a check of the first bytes of a file when one isn't enough
+type signature struct {
+	FileType
+	match func(head []byte) bool
+}
+
+var ErrUnknownType = errors.New("the file format couldn't be recognized")
+
+// How much of a file is read to recognize it, tar headers and office document parts sit past the first bytes
+const sniffSize = 4096
+
+var signatures = []signature{
+	// Images
+	{FileType{"image", "jpg", []string{"jpeg", "jpe", "jfif"}}, prefix("\xFF\xD8\xFF")},
+	{FileType{"image", "png", nil}, prefix("\x89PNG\r\n\x1A\n")},
+	{FileType{"image", "gif", nil}, anyPrefix("GIF87a", "GIF89a")},
+	{FileType{"image", "webp", nil}, riff("WEBP")},
+	{FileType{"image", "tiff", []string{"tif", "dng", "cr2", "nef", "arw", "orf", "rw2", "pef", "srw"}}, anyPrefix("II*\x00", "MM\x00*")},
+	{FileType{"image", "bmp", []string{"dib"}}, bitmap},
+	{FileType{"image", "ico", []string{"cur"}}, prefix("\x00\x00\x01\x00")},
+	{FileType{"image", "psd", nil}, prefix("8BPS")},
+	{FileType{"image", "avif", nil}, isoBrand("avif", "avis")},
+	{FileType{"image", "heic", []string{"heif", "hif"}}, isoBrand("heic", "heix", "heim", "heis", "hevc", "hevx", "mif1", "msf1")},
+	{FileType{"image", "cr3", nil}, isoBrand("crx ")},
+
+	// Audio, before video so the audio-only MP4 brands win
+	{FileType{"audio", "m4a", []string{"m4b", "m4p", "mp4"}}, isoBrand("M4A ", "M4B ", "M4P ")},
+	{FileType{"audio", "mp3", nil}, mpegAudio},
+	{FileType{"audio", "flac", nil}, prefix("fLaC")},
+	{FileType{"audio", "opus", []string{"ogg"}}, func(head []byte) bool { return hasPrefix(head, "OggS") && at(head, 28, "OpusHead") }},
+	{FileType{"audio", "ogg", []string{"oga", "ogv", "spx"}}, prefix("OggS")},
+	{FileType{"audio", "wav", []string{"wave"}}, riff("WAVE")},
+	{FileType{"audio", "aiff", []string{"aif", "aifc"}}, func(head []byte) bool { return hasPrefix(head, "FORM") && (at(head, 8, "AIFF") || at(head, 8, "AIFC")) }},
+	{FileType{"audio", "mid", []string{"midi"}}, prefix("MThd")},
+
+	// Video
+	{FileType{"video", "mov", []string{"qt"}}, isoBrand("qt  ")},
+	{FileType{"video", "3gp", []string{"3g2"}}, isoBrand("3gp4", "3gp5", "3gp6", "3g2a")},
+	{FileType{"video", "mp4", []string{"m4v", "m4a", "m4b", "mov", "f4v"}}, isoBrand("isom", "iso2", "iso4", "iso5", "iso6", "mp41", "mp42", "avc1", "dash", "M4V ", "MSNV", "f4v ")},
+	{FileType{"video", "webm", nil}, ebml("webm")},
+	{FileType{"video", "mkv", []string{"mka", "mks", "mk3d"}}, ebml("matroska")},
+	{FileType{"video", "avi", nil}, riff("AVI ")},
+	{FileType{"video", "flv", nil}, prefix("FLV\x01")},
+	{FileType{"video", "wmv", []string{"wma", "asf"}}, prefix("\x30\x26\xB2\x75\x8E\x66\xCF\x11")},
+	{FileType{"video", "mpg", []string{"mpeg", "vob"}}, prefix("\x00\x00\x01\xBA")},
+
+	// Documents and archives built on zip, told apart by the parts they hold
+	{FileType{"document", "epub", nil}, func(head []byte) bool {
+		return hasPrefix(head, "PK\x03\x04") && at(head, 30, "mimetypeapplication/epub+zip")
+	}},
+	{FileType{"document", "odt", nil}, zipMimetype("application/vnd.oasis.opendocument.text")},
+	{FileType{"document", "ods", nil}, zipMimetype("application/vnd.oasis.opendocument.spreadsheet")},
+	{FileType{"document", "odp", nil}, zipMimetype("application/vnd.oasis.opendocument.presentation")},
+	{FileType{"document", "docx", []string{"docm", "dotx"}}, zipPart("word/")},
+	{FileType{"document", "xlsx", []string{"xlsm", "xltx"}}, zipPart("xl/")},
+	{FileType{"document", "pptx", []string{"pptm", "potx"}}, zipPart("ppt/")},
+	{FileType{"archive", "jar", []string{"apk", "war", "ear", "aar", "zip"}}, zipPart("META-INF/")},
+	// Documents whose parts sit past the first bytes, like after a big thumbnail, are only known to be zips
+	{FileType{"archive", "zip", []string{"docx", "docm", "xlsx", "xlsm", "pptx", "pptm", "odt", "ods", "odp", "epub", "jar", "war", "cbz", "xpi", "apk", "ipa", "whl", "nupkg", "vsix", "kmz", "3mf", "sketch"}}, anyPrefix("PK\x03\x04", "PK\x05\x06", "PK\x07\x08")},
+
+	// Documents
+	{FileType{"document", "pdf", []string{"ai"}}, prefix("%PDF-")},
+	{FileType{"document", "rtf", nil}, prefix("{\\rtf")},
+	{FileType{"document", "ps", []string{"eps"}}, prefix("%!PS")},
+	{FileType{"document", "doc", []string{"xls", "ppt", "msi", "msg", "dot", "xlt", "pot", "vsd", "pub"}}, prefix("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")},
+	{FileType{"document", "sqlite", []string{"db", "sqlite3", "db3"}}, prefix("SQLite format 3\x00")},
+
+	// Archives
+	{FileType{"archive", "gz", []string{"tgz", "tar.gz", "svgz"}}, prefix("\x1F\x8B")},
+	{FileType{"archive", "bz2", []string{"tbz2", "tbz", "tar.bz2"}}, bzip2},
+	{FileType{"archive", "xz", []string{"txz", "tar.xz"}}, prefix("\xFD7zXZ\x00")},
+	{FileType{"archive", "zst", []string{"tzst", "tar.zst"}}, prefix("\x28\xB5\x2F\xFD")},
+	{FileType{"archive", "7z", nil}, prefix("7z\xBC\xAF\x27\x1C")},
+	{FileType{"archive", "rar", []string{"cbr"}}, prefix("Rar!\x1A\x07")},
+	{FileType{"archive", "tar", []string{"ova"}}, func(head []byte) bool { return at(head, 257, "ustar") }},
+	{FileType{"archive", "cab", nil}, prefix("MSCF")},
+
+	// Executables
+	{FileType{"executable", "exe", []string{"dll", "sys", "scr", "ocx", "efi", "cpl", "drv", "com", "mui"}}, portableExecutable},
+	{FileType{"executable", "", []string{"so", "o", "ko", "elf", "bin", "out", "axf", "prx"}}, prefix("\x7FELF")},
+	{FileType{"executable", "", []string{"dylib", "bundle", "o", "bin"}}, anyPrefix("\xFE\xED\xFA\xCE", "\xFE\xED\xFA\xCF", "\xCE\xFA\xED\xFE", "\xCF\xFA\xED\xFE")},
+	{FileType{"executable", "class", nil}, javaClass},
+	{FileType{"executable", "wasm", nil}, prefix("\x00asm")},
+
+	// Fonts
+	{FileType{"font", "woff", nil}, prefix("wOFF")},
+	{FileType{"font", "woff2", nil}, prefix("wOF2")},
+	{FileType{"font", "otf", nil}, prefix("OTTO")},
+	{FileType{"font", "ttf", []string{"ttc"}}, anyPrefix("\x00\x01\x00\x00\x00", "ttcf")},
+}
+
+// Recognizes the format of a file by its first bytes
+func SniffType(path string) (FileType, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FileType{}, err
+	}
+	defer file.Close()
+
+	head := make([]byte, sniffSize)
+	read, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return FileType{}, ErrUnknownType
+	}
+	return SniffBytes(head[:read])
+}
+
+// Recognizes the format of the first bytes of a file
+func SniffBytes(head []byte) (FileType, error) {
+	for _, signature := range signatures {
+		if signature.match(head) {
+			return signature.FileType, nil
+		}
+	}
+	return FileType{}, ErrUnknownType
+}
+
+// Lists every extension the formats know, to tell real extensions apart from dots in names like "v1.2"
+func KnownExtensions() map[string]bool {
+	known := make(map[string]bool)
+	for _, signature := range signatures {
+		if signature.Extension != "" {
+			known[signature.Extension] = true
+		}
+		for _, accepted := range signature.Accepts {
+			known[accepted] = true
+		}
+	}
+	return known
+}
+
+func hasPrefix(head []byte, magic string) bool {
+	return bytes.HasPrefix(head, []byte(magic))
+}
+
+func at(head []byte, offset int, magic string) bool {
+	return len(head) >= offset+len(magic) && string(head[offset:offset+len(magic)]) == magic
+}
+
+func prefix(magic string) func([]byte) bool {
+	return func(head []byte) bool { return hasPrefix(head, magic) }
+}
+
+func anyPrefix(magics ...string) func([]byte) bool {
+	return func(head []byte) bool {
+		for _, magic := range magics {
+			if hasPrefix(head, magic) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RIFF containers name their format at offset 8
+func riff(format string) func([]byte) bool {
+	return func(head []byte) bool { return hasPrefix(head, "RIFF") && at(head, 8, format) }
+}
+
+// ISO base media files (MP4, MOV, HEIC...) name their major brand in the ftyp box
+func isoBrand(brands ...string) func([]byte) bool {
+	return func(head []byte) bool {
+		if !at(head, 4, "ftyp") {
+			return false
+		}
+		for _, brand := range brands {
+			if at(head, 8, brand) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Matroska and WebM share the EBML header and differ in its doc type
+func ebml(docType string) func([]byte) bool {
+	return func(head []byte) bool {
+		return hasPrefix(head, "\x1A\x45\xDF\xA3") && bytes.Contains(head[:min(len(head), 64)], []byte(docType))
+	}
+}
+
+// OpenDocument files start with an uncompressed "mimetype" entry
+func zipMimetype(mimetype string) func([]byte) bool {
+	return func(head []byte) bool { return hasPrefix(head, "PK\x03\x04") && at(head, 30, "mimetype"+mimetype) }
+}
+
+// Office Open XML and Java archives are recognized by the folders of their first entries
+func zipPart(part string) func([]byte) bool {
+	return func(head []byte) bool {
+		for _, name := range zipEntryNames(head) {
+			if strings.HasPrefix(name, part) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Names of the zip entries whose local headers are in the first bytes. The compressed size skips to the next header,
+// entries written with a data descriptor don't have it there, so the next signature is searched instead.
+func zipEntryNames(head []byte) (names []string) {
+	for offset := 0; at(head, offset, "PK\x03\x04") && offset+30 <= len(head); {
+		flags := binary.LittleEndian.Uint16(head[offset+6:])
+		size := int(binary.LittleEndian.Uint32(head[offset+18:]))
+		nameLength := int(binary.LittleEndian.Uint16(head[offset+26:]))
+		extraLength := int(binary.LittleEndian.Uint16(head[offset+28:]))
+		data := offset + 30 + nameLength + extraLength
+		if offset+30+nameLength > len(head) {
+			break
+		}
+		names = append(names, string(head[offset+30:offset+30+nameLength]))
+
+		if flags&0x08 == 0 && size >= 0 {
+			offset = data + size
+		} else if next := bytes.Index(head[min(data, len(head)):], []byte("PK\x03\x04")); next >= 0 {
+			offset = data + next
+		} else {
+			break
+		}
+	}
+	return names
+}
+
+// "BM" followed by the file size and reserved zeros, two letters alone match too many text files
+func bitmap(head []byte) bool {
+	return hasPrefix(head, "BM") && len(head) >= 14 && binary.LittleEndian.Uint32(head[6:]) == 0
+}
+
+// Windows executables start with a DOS stub whose header points to the PE signature, a text starting with "MZ" doesn't
+func portableExecutable(head []byte) bool {
+	if !hasPrefix(head, "MZ") || len(head) < 0x40 {
+		return false
+	}
+	offset := binary.LittleEndian.Uint32(head[0x3C:])
+	return offset <= uint32(len(head)) && at(head, int(offset), "PE\x00\x00")
+}
+
+// bzip2 streams give a block size from 1 to 9 and start with the block magic (the digits of pi), or the end of stream
+// magic (the square root of pi) when empty
+func bzip2(head []byte) bool {
+	if !hasPrefix(head, "BZh") || len(head) < 10 || head[3] < '1' || head[3] > '9' {
+		return false
+	}
+	return at(head, 4, "\x31\x41\x59\x26\x53\x59") || at(head, 4, "\x17\x72\x45\x38\x50\x90")
+}
+
+// MP3 files start with an ID3v2 tag or straight with an MPEG audio frame sync
+func mpegAudio(head []byte) bool {
+	if hasPrefix(head, "ID3") {
+		return true
+	}
+	return len(head) >= 2 && head[0] == 0xFF && (head[1]&0xE0) == 0xE0 && (head[1]&0x06) != 0
+}
+
+// Java classes share 0xCAFEBABE with universal Mach-O binaries, which have a small architecture count instead of a version
+func javaClass(head []byte) bool {
+	return hasPrefix(head, "\xCA\xFE\xBA\xBE") && len(head) >= 8 && binary.BigEndian.Uint16(head[6:]) >= 45
+}
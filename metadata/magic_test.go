@@ -0,0 +1,124 @@
+package metadata
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+type zipEntry struct {
+	name    string
+	content []byte
+	method  uint16
+}
+
+// Writes a real archive, with data descriptors after each entry like most zip tools
+func makeZip(t *testing.T, entries ...zipEntry) []byte {
+	t.Helper()
+	buffer := bytes.Buffer{}
+	writer := zip.NewWriter(&buffer)
+	for _, entry := range entries {
+		file, err := writer.CreateHeader(&zip.FileHeader{Name: entry.name, Method: entry.method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write(entry.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// Writes a local header with its sizes and no data descriptor, like office suites do
+func craftedEntry(name string, content []byte) []byte {
+	header := make([]byte, 30)
+	copy(header, "PK\x03\x04")
+	binary.LittleEndian.PutUint32(header[18:], uint32(len(content)))
+	binary.LittleEndian.PutUint32(header[22:], uint32(len(content)))
+	binary.LittleEndian.PutUint16(header[26:], uint16(len(name)))
+	return append(append(header, name...), content...)
+}
+
+func TestSniffZipParts(t *testing.T) {
+	thumbnail := make([]byte, 8192)
+	rand.New(rand.NewSource(1)).Read(thumbnail)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		extension string
+	}{
+		{"docx", makeZip(t,
+			zipEntry{"[Content_Types].xml", []byte("<Types/>"), zip.Deflate},
+			zipEntry{"_rels/.rels", []byte("<Relationships/>"), zip.Deflate},
+			zipEntry{"word/document.xml", []byte("<document/>"), zip.Deflate},
+		), "docx"},
+		{"xlsx", makeZip(t, zipEntry{"[Content_Types].xml", nil, zip.Deflate}, zipEntry{"xl/workbook.xml", nil, zip.Deflate}), "xlsx"},
+		{"pptx", makeZip(t, zipEntry{"ppt/presentation.xml", []byte("<p/>"), zip.Store}), "pptx"},
+		{"jar", makeZip(t, zipEntry{"META-INF/MANIFEST.MF", []byte("Manifest-Version: 1.0\n"), zip.Deflate}), "jar"},
+		{"epub", makeZip(t, zipEntry{"mimetype", []byte("application/epub+zip"), zip.Store}), "epub"},
+		{"folder named like a part", makeZip(t, zipEntry{"password/", nil, zip.Store}, zipEntry{"password/keys.txt", []byte("1234"), zip.Deflate}), "zip"},
+		{"folder ending like a part", makeZip(t, zipEntry{"pixl/", nil, zip.Store}, zipEntry{"pixl/a.png", []byte("png"), zip.Deflate}), "zip"},
+		{"part name in a file", makeZip(t, zipEntry{"notes.txt", []byte("see word/ and xl/"), zip.Store}), "zip"},
+		{"crafted docx", append(append(craftedEntry("[Content_Types].xml", []byte("<Types/>")), craftedEntry("_rels/.rels", nil)...), craftedEntry("word/document.xml", nil)...), "docx"},
+		{"crafted part past the head", append(craftedEntry("docProps/thumbnail.jpeg", thumbnail), craftedEntry("word/document.xml", nil)...), "zip"},
+		{"truncated header", []byte("PK\x03\x04\x14\x00"), "zip"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fileType, err := SniffBytes(test.data[:min(len(test.data), sniffSize)])
+			if err != nil {
+				t.Fatalf("SniffBytes() error = %v", err)
+			}
+			if fileType.Extension != test.extension {
+				t.Errorf("SniffBytes() = %q, want %q", fileType.Extension, test.extension)
+			}
+		})
+	}
+}
+
+func TestZipAcceptsDocuments(t *testing.T) {
+	zipType, err := SniffBytes([]byte("PK\x03\x04"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, extension := range []string{"zip", "docx", "xlsx", "pptx", "odt", "ods", "odp", "epub", "jar", "apk"} {
+		if !zipType.Matches(extension) {
+			t.Errorf("zip doesn't accept %q", extension)
+		}
+	}
+}
+
+func TestSniffWeakSignatures(t *testing.T) {
+	pe := make([]byte, 0x84)
+	copy(pe, "MZ")
+	binary.LittleEndian.PutUint32(pe[0x3C:], 0x80)
+	copy(pe[0x80:], "PE\x00\x00")
+
+	tests := []struct {
+		name      string
+		data      []byte
+		extension string
+	}{
+		{"text starting with MZ", []byte("MZ notes about the meeting\n"), ""},
+		{"portable executable", pe, "exe"},
+		{"text starting with BZh", []byte("BZh is a prefix of bzip2\n"), ""},
+		{"bzip2", []byte("BZh91AY&SY\x00\x00"), "bz2"},
+		{"empty bzip2", []byte("BZh9\x17\x72\x45\x38\x50\x90\x00\x00\x00\x00"), "bz2"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fileType, _ := SniffBytes(test.data)
+			if fileType.Extension != test.extension {
+				t.Errorf("SniffBytes() = %q, want %q", fileType.Extension, test.extension)
+			}
+		})
+	}
+}